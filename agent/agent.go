@@ -0,0 +1,306 @@
+// Package agent holds an unlocked private key in a long running process and
+// signs for other processes of the same user over a Unix domain socket, so
+// that the key file is read once instead of on every signature:
+//
+//	a, err := agent.New(agent.Config{Key: key, Timeout: 15 * time.Minute})
+//	l, err := agent.Listen(agent.DefaultSocket())
+//	err = a.Serve(l)
+//
+// The Client implements lk.Signer:
+//
+//	client, err := agent.Dial(agent.DefaultSocket())
+//	license, err := lk.NewClaimsLicense(client, claims)
+//
+// The agent only answers the peers running as its own user, or as one of
+// Config.AllowedUIDs, as told by the peer credentials of the socket. The
+// peer credentials are read on Linux and macOS, the connections are refused
+// on the other systems.
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/phox/gmsm-lk"
+)
+
+// SocketEnvVar is the environment variable holding the socket of the agent.
+const SocketEnvVar = "LK_AGENT_SOCK"
+
+// maxRequestSize is the maximum size of a request line.
+const maxRequestSize = 1 << 20
+
+var (
+	// ErrLocked is returned when the agent forgot its key, after the
+	// timeout or a call to Lock.
+	ErrLocked = errors.New("agent: locked")
+	// ErrDenied is returned when the signature was not confirmed.
+	ErrDenied = errors.New("agent: signature denied")
+	// ErrPeerNotAllowed is returned when the peer runs as another user.
+	ErrPeerNotAllowed = errors.New("agent: peer not allowed")
+)
+
+// DefaultSocket returns the path of the socket in the environment variable,
+// or in $XDG_RUNTIME_DIR, or in a directory of the user in the temporary
+// directory.
+func DefaultSocket() string {
+	if path := os.Getenv(SocketEnvVar); path != "" {
+		return path
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "lkgen", "agent.sock")
+	}
+	return filepath.Join(os.TempDir(), "lkgen-"+strconv.Itoa(os.Getuid()), "agent.sock")
+}
+
+// Listen creates the socket, in a directory only accessible by the user: it
+// must be owned by the user with mode 0700 and not be a symbolic link. A
+// stale socket left by an agent that didn't stop cleanly is replaced, but
+// not the socket of a running agent.
+func Listen(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := checkSocketDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err == nil {
+		if c, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			_ = c.Close()
+			return nil, fmt.Errorf("agent: an agent is already listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Request is a signature request, given to Config.Confirm.
+type Request struct {
+	// UID and PID identify the peer process, PID is zero if unknown.
+	UID int
+	PID int
+	// Message is the message to sign.
+	Message []byte
+	// Description is what the client says it signs, such as the serial and
+	// the subject of a license, empty if it didn't tell. It is not checked
+	// against the message.
+	Description string
+}
+
+// Config is the configuration of an Agent.
+type Config struct {
+	// Key is the unlocked key.
+	Key lk.Signer
+	// Timeout locks the agent when no signature was requested during this
+	// time, never if zero.
+	Timeout time.Duration
+	// Confirm is asked before each signature if not nil, the signature is
+	// denied if it returns false. The calls are serialized.
+	Confirm func(Request) bool
+	// AllowedUIDs are the users allowed in addition to the user of the
+	// agent.
+	AllowedUIDs []int
+}
+
+// Agent signs with its key the requests received on its listeners, until
+// it is locked.
+type Agent struct {
+	cfg       Config
+	confirmMu sync.Mutex
+
+	public *lk.PublicKey
+
+	mu        sync.Mutex
+	key       lk.Signer
+	timer     *time.Timer
+	listeners []net.Listener
+}
+
+// New returns an unlocked agent, the timeout starts now. The agent keeps
+// the key out of its configuration, so that Lock forgets it.
+func New(cfg Config) (*Agent, error) {
+	if cfg.Key == nil {
+		return nil, errors.New("agent: a key is required")
+	}
+	a := &Agent{cfg: cfg, key: cfg.Key, public: cfg.Key.GetPublicKey()}
+	a.cfg.Key = nil
+	if cfg.Timeout > 0 {
+		a.timer = time.AfterFunc(cfg.Timeout, a.Lock)
+	}
+	return a, nil
+}
+
+// Lock forgets the key and closes the listeners, Serve returns ErrLocked.
+// A locked agent can't be unlocked, a new one must be created.
+func (a *Agent) Lock() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.key = nil
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+	for _, l := range a.listeners {
+		_ = l.Close()
+	}
+	a.listeners = nil
+}
+
+// Locked tells if the agent is locked.
+func (a *Agent) Locked() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.key == nil
+}
+
+// PublicKey returns the public key of the agent.
+func (a *Agent) PublicKey() *lk.PublicKey {
+	return a.public
+}
+
+// Serve answers the connections of the listener until the agent is locked
+// or the listener fails.
+func (a *Agent) Serve(l net.Listener) error {
+	a.mu.Lock()
+	if a.key == nil {
+		a.mu.Unlock()
+		_ = l.Close()
+		return ErrLocked
+	}
+	a.listeners = append(a.listeners, l)
+	a.mu.Unlock()
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if a.Locked() {
+				return ErrLocked
+			}
+			return err
+		}
+		go a.serveConn(c)
+	}
+}
+
+// request is a line sent by the client.
+type request struct {
+	// Op is "public" or "sign".
+	Op          string `json:"op"`
+	Message     []byte `json:"message,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// response is the line answering a request.
+type response struct {
+	PublicKey string `json:"public_key,omitempty"`
+	R         string `json:"r,omitempty"`
+	S         string `json:"s,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func (a *Agent) serveConn(c net.Conn) {
+	defer c.Close()
+	enc := json.NewEncoder(c)
+
+	uid, pid, err := a.checkPeer(c)
+	if err != nil {
+		_ = enc.Encode(&response{Error: err.Error()})
+		return
+	}
+
+	r := bufio.NewReaderSize(c, maxRequestSize)
+	for {
+		_ = c.SetReadDeadline(time.Now().Add(time.Minute))
+		line, err := r.ReadSlice('\n')
+		if err != nil {
+			if err == bufio.ErrBufferFull {
+				_ = enc.Encode(&response{Error: "agent: request too large"})
+			}
+			return
+		}
+
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			_ = enc.Encode(&response{Error: "agent: " + err.Error()})
+			return
+		}
+		if err := enc.Encode(a.handle(&req, uid, pid)); err != nil {
+			return
+		}
+	}
+}
+
+func (a *Agent) checkPeer(c net.Conn) (uid, pid int, err error) {
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return 0, 0, ErrPeerNotAllowed
+	}
+	uid, pid, err = peerCred(uc)
+	if err != nil {
+		return 0, 0, err
+	}
+	if uid == os.Getuid() {
+		return uid, pid, nil
+	}
+	for _, allowed := range a.cfg.AllowedUIDs {
+		if uid == allowed {
+			return uid, pid, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("%w: uid %d", ErrPeerNotAllowed, uid)
+}
+
+func (a *Agent) handle(req *request, uid, pid int) *response {
+	switch req.Op {
+	case "public":
+		return &response{PublicKey: a.PublicKey().ToB32String()}
+	case "sign":
+		if a.Locked() {
+			return &response{Error: ErrLocked.Error()}
+		}
+		if a.cfg.Confirm != nil {
+			a.confirmMu.Lock()
+			ok := a.cfg.Confirm(Request{UID: uid, PID: pid, Message: req.Message, Description: req.Description})
+			a.confirmMu.Unlock()
+			if !ok {
+				return &response{Error: ErrDenied.Error()}
+			}
+		}
+
+		a.mu.Lock()
+		key := a.key
+		if key != nil && a.timer != nil {
+			a.timer.Reset(a.cfg.Timeout)
+		}
+		a.mu.Unlock()
+		if key == nil {
+			return &response{Error: ErrLocked.Error()}
+		}
+
+		r, s, err := key.SignMessage(req.Message)
+		if err != nil {
+			return &response{Error: "agent: " + err.Error()}
+		}
+		return &response{R: r.Text(16), S: s.Text(16)}
+	}
+	return &response{Error: fmt.Sprintf("agent: unknown operation %q", req.Op)}
+}
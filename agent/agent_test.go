@@ -0,0 +1,112 @@
+package agent_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/phox/gmsm-lk"
+	"github.com/phox/gmsm-lk/agent"
+	"github.com/stretchr/testify/require"
+)
+
+func start(t *testing.T, cfg agent.Config) (*agent.Agent, string, chan error) {
+	key, err := lk.NewPrivateKey()
+	require.NoError(t, err)
+	cfg.Key = key
+
+	a, err := agent.New(cfg)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "lkgen", "agent.sock")
+	l, err := agent.Listen(path)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() { done <- a.Serve(l) }()
+	t.Cleanup(a.Lock)
+	return a, path, done
+}
+
+func TestAgent(t *testing.T) {
+	a, path, done := start(t, agent.Config{})
+
+	client, err := agent.Dial(path)
+	require.NoError(t, err)
+	require.Equal(t, a.PublicKey().Fingerprint(), client.GetPublicKey().Fingerprint())
+
+	license, err := lk.NewClaimsLicense(client, &lk.Claims{Subject: "ACME"})
+	require.NoError(t, err)
+	require.NoError(t, license.VerifyErr(a.PublicKey()))
+
+	_, err = agent.Listen(path)
+	require.ErrorContains(t, err, "already listening")
+
+	a.Lock()
+	require.ErrorIs(t, <-done, agent.ErrLocked)
+	_, _, err = client.SignMessage([]byte("message"))
+	require.Error(t, err)
+	require.Equal(t, client.GetPublicKey().Fingerprint(), a.PublicKey().Fingerprint())
+}
+
+func TestAgentConfirm(t *testing.T) {
+	var requests []agent.Request
+	allow := false
+	_, path, _ := start(t, agent.Config{Confirm: func(r agent.Request) bool {
+		requests = append(requests, r)
+		return allow
+	}})
+	client, err := agent.Dial(path)
+	require.NoError(t, err)
+
+	_, _, err = client.SignMessage([]byte("message"))
+	require.ErrorIs(t, err, agent.ErrDenied)
+
+	allow = true
+	_, _, err = client.WithDescription("license s1 for ACME").SignMessage([]byte("message"))
+	require.NoError(t, err)
+
+	require.Len(t, requests, 2)
+	require.Equal(t, []byte("message"), requests[1].Message)
+	require.Empty(t, requests[0].Description)
+	require.Equal(t, "license s1 for ACME", requests[1].Description)
+}
+
+func TestAgentTimeout(t *testing.T) {
+	a, path, done := start(t, agent.Config{Timeout: 200 * time.Millisecond})
+	client, err := agent.Dial(path)
+	require.NoError(t, err)
+
+	// each signature restarts the timeout
+	for i := 0; i < 3; i++ {
+		time.Sleep(100 * time.Millisecond)
+		_, _, err = client.SignMessage([]byte("message"))
+		require.NoError(t, err)
+	}
+
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, agent.ErrLocked)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the agent didn't lock")
+	}
+	require.True(t, a.Locked())
+
+	// the socket is removed, a new agent can listen on it
+	l, err := agent.Listen(path)
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+}
+
+func TestListenSocketDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "open")
+	require.NoError(t, os.Mkdir(dir, 0700))
+	require.NoError(t, os.Chmod(dir, 0755))
+	_, err := agent.Listen(filepath.Join(dir, "agent.sock"))
+	require.ErrorContains(t, err, "instead of 0700")
+
+	link := filepath.Join(t.TempDir(), "link")
+	require.NoError(t, os.Symlink(t.TempDir(), link))
+	_, err = agent.Listen(filepath.Join(link, "agent.sock"))
+	require.ErrorContains(t, err, "symbolic link")
+}
@@ -0,0 +1,95 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/phox/gmsm-lk"
+)
+
+// Client signs with the key of an agent. It implements lk.Signer and is
+// safe for concurrent use, each request opens a connection.
+type Client struct {
+	path        string
+	public      *lk.PublicKey
+	description string
+}
+
+// Dial connects to the agent listening on the socket and gets its public
+// key.
+func Dial(path string) (*Client, error) {
+	c := &Client{path: path}
+	resp, err := c.call(&request{Op: "public"})
+	if err != nil {
+		return nil, err
+	}
+	if c.public, err = lk.PublicKeyFromB32String(resp.PublicKey); err != nil {
+		return nil, fmt.Errorf("agent: %w", err)
+	}
+	return c, nil
+}
+
+// GetPublicKey returns the public key of the agent.
+func (c *Client) GetPublicKey() *lk.PublicKey {
+	return c.public
+}
+
+// WithDescription returns a client sending the description with its
+// signature requests, shown by the agent when it asks for a confirmation.
+func (c *Client) WithDescription(description string) *Client {
+	d := *c
+	d.description = description
+	return &d
+}
+
+// SignMessage asks the agent to sign the message. The error is ErrLocked or
+// ErrDenied if the agent refused.
+func (c *Client) SignMessage(msg []byte) (r, s *big.Int, err error) {
+	resp, err := c.call(&request{Op: "sign", Message: msg, Description: c.description})
+	if err != nil {
+		return nil, nil, err
+	}
+	r, okR := new(big.Int).SetString(resp.R, 16)
+	s, okS := new(big.Int).SetString(resp.S, 16)
+	if !okR || !okS {
+		return nil, nil, errors.New("agent: invalid signature")
+	}
+	return r, s, nil
+}
+
+func (c *Client) call(req *request) (*response, error) {
+	conn, err := net.DialTimeout("unix", c.path, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+	var resp response
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("agent: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, responseError(resp.Error)
+	}
+	return &resp, nil
+}
+
+// responseError returns the error of the message, matching the sentinel
+// errors it starts with.
+func responseError(msg string) error {
+	for _, err := range []error{ErrLocked, ErrDenied, ErrPeerNotAllowed} {
+		if rest, ok := strings.CutPrefix(msg, err.Error()); ok {
+			return fmt.Errorf("%w%s", err, rest)
+		}
+	}
+	return errors.New(msg)
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package agent
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+)
+
+func peerCred(c *net.UnixConn) (uid, pid int, err error) {
+	return 0, 0, fmt.Errorf("%w: peer credentials are not supported on %s", ErrPeerNotAllowed, runtime.GOOS)
+}
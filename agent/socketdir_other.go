@@ -0,0 +1,9 @@
+//go:build !unix
+
+package agent
+
+// checkSocketDir does nothing, the directory has no owner and mode to check
+// on this system.
+func checkSocketDir(string) error {
+	return nil
+}
@@ -0,0 +1,30 @@
+//go:build unix
+
+package agent
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkSocketDir checks that the directory of the socket is not a symbolic
+// link, is owned by the user and only accessible by the user.
+func checkSocketDir(dir string) error {
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	switch {
+	case fi.Mode()&os.ModeSymlink != 0:
+		return fmt.Errorf("agent: %s is a symbolic link", dir)
+	case !fi.IsDir():
+		return fmt.Errorf("agent: %s is not a directory", dir)
+	case fi.Mode().Perm() != 0700:
+		return fmt.Errorf("agent: %s has mode %#o instead of 0700", dir, fi.Mode().Perm())
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); !ok || int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("agent: %s is not owned by the user", dir)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package lk
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"runtime"
+	"sync"
+
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/sm3"
+)
+
+// sm2DefaultUID is the user id used by the SM2 signatures of the library.
+var sm2DefaultUID = []byte("1234567812345678")
+
+// PreparedPublicKey is a public key converted once for SM2, with its ZA
+// digest precomputed, to verify many licenses without redoing that work on
+// every call.
+type PreparedPublicKey struct {
+	key *PublicKey
+	pub *ecdsa.PublicKey
+	za  []byte
+}
+
+// Prepare converts the public key for repeated verifications.
+func (k *PublicKey) Prepare() (*PreparedPublicKey, error) {
+	pub, err := sm2.NewPublicKey(k.ToBytes())
+	if err != nil {
+		return nil, err
+	}
+	za, err := sm2.CalculateZA(pub, sm2DefaultUID)
+	if err != nil {
+		return nil, err
+	}
+	return &PreparedPublicKey{key: k, pub: pub, za: za}, nil
+}
+
+// PublicKey returns the prepared public key.
+func (k *PreparedPublicKey) PublicKey() *PublicKey {
+	return k.key
+}
+
+// VerifyPrepared verifies the License with a prepared public key.
+func (l *License) VerifyPrepared(k *PreparedPublicKey) (bool, error) {
+	if err := checkSignature(l.R, l.S); err != nil {
+		return false, err
+	}
+
+	h, err := l.hash()
+	if err != nil {
+		return false, err
+	}
+
+	md := sm3.New()
+	if _, err := md.Write(k.za); err != nil {
+		return false, err
+	}
+	if _, err := md.Write(h); err != nil {
+		return false, err
+	}
+	return sm2.Verify(k.pub, md.Sum(nil), l.R, l.S), nil
+}
+
+// BatchResult is the result of one item of a batch. Err is set if the item
+// failed, other items of the batch are not affected.
+type BatchResult struct {
+	License *License
+	Err     error
+}
+
+// runBatch calls fn for every index with a pool of workers. The indexes that
+// are not started before ctx is done get the context error.
+func runBatch(ctx context.Context, n, workers int, fn func(i int) error) []error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	errs := make([]error, n)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+
+	i := 0
+feed:
+	for ; i < n; i++ {
+		select {
+		case <-ctx.Done():
+			break feed
+		case jobs <- i:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	for ; i < n; i++ {
+		errs[i] = ctx.Err()
+	}
+	return errs
+}
+
+// BatchSigner creates many licenses in parallel with the same key.
+type BatchSigner struct {
+	key     Signer
+	workers int
+}
+
+// NewBatchSigner returns a BatchSigner using the number of workers, or
+// GOMAXPROCS workers if workers is not positive.
+func NewBatchSigner(k Signer, workers int) *BatchSigner {
+	return &BatchSigner{key: k, workers: workers}
+}
+
+// Sign creates a license for each data. The results are in the same order
+// as the data. The returned error is the context error if the batch was
+// cancelled.
+func (b *BatchSigner) Sign(ctx context.Context, data [][]byte) ([]BatchResult, error) {
+	res := make([]BatchResult, len(data))
+	errs := runBatch(ctx, len(data), b.workers, func(i int) error {
+		l, err := NewLicense(b.key, data[i])
+		res[i].License = l
+		return err
+	})
+
+	for i, err := range errs {
+		res[i].Err = err
+	}
+	return res, ctx.Err()
+}
+
+// BatchVerifier verifies many licenses in parallel with the same key.
+type BatchVerifier struct {
+	key     *PreparedPublicKey
+	workers int
+}
+
+// NewBatchVerifier returns a BatchVerifier using the number of workers, or
+// GOMAXPROCS workers if workers is not positive.
+func NewBatchVerifier(k *PublicKey, workers int) (*BatchVerifier, error) {
+	pk, err := k.Prepare()
+	if err != nil {
+		return nil, err
+	}
+	return &BatchVerifier{key: pk, workers: workers}, nil
+}
+
+// Verify verifies the licenses. The results are in the same order as the
+// licenses, with ErrBadSignature for the licenses that don't match the
+// key. The returned error is the context error if the batch was cancelled.
+func (b *BatchVerifier) Verify(ctx context.Context, licenses []*License) ([]BatchResult, error) {
+	res := make([]BatchResult, len(licenses))
+	errs := runBatch(ctx, len(licenses), b.workers, func(i int) error {
+		res[i].License = licenses[i]
+		if ok, err := licenses[i].VerifyPrepared(b.key); err != nil {
+			return err
+		} else if !ok {
+			return ErrBadSignature
+		}
+		return nil
+	})
+
+	for i, err := range errs {
+		res[i].Err = err
+	}
+	return res, ctx.Err()
+}
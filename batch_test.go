@@ -0,0 +1,160 @@
+package lk_test
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+
+	lk "github.com/phox/gmsm-lk"
+)
+
+func (s *Suite) TestBatch() {
+	privateKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+	wrongKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+
+	data := make([][]byte, 50)
+	for i := range data {
+		data[i] = s.RandomBytes(100)
+	}
+
+	res, err := lk.NewBatchSigner(privateKey, 4).Sign(context.Background(), data)
+	s.Require().NoError(err)
+	s.Require().Len(res, len(data))
+
+	licenses := make([]*lk.License, len(res))
+	for i, r := range res {
+		s.Require().NoError(r.Err)
+		s.Require().Equal(data[i], r.License.Data)
+		licenses[i] = r.License
+	}
+
+	s.Run("should verify a batch", func() {
+		forged, err := lk.NewLicense(wrongKey, data[0])
+		s.Require().NoError(err)
+
+		v, err := lk.NewBatchVerifier(privateKey.GetPublicKey(), 0)
+		s.Require().NoError(err)
+
+		res, err := v.Verify(context.Background(), append(licenses, forged))
+		s.Require().NoError(err)
+		for _, r := range res[:len(licenses)] {
+			s.Require().NoError(r.Err)
+		}
+		s.Require().ErrorIs(res[len(licenses)].Err, lk.ErrBadSignature)
+	})
+
+	s.Run("should stop on cancellation", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		res, err := lk.NewBatchSigner(privateKey, 2).Sign(ctx, data)
+		s.Require().ErrorIs(err, context.Canceled)
+		s.Require().Len(res, len(data))
+		s.Require().ErrorIs(res[len(res)-1].Err, context.Canceled)
+	})
+
+	s.Run("should verify with a prepared key", func() {
+		pk, err := privateKey.GetPublicKey().Prepare()
+		s.Require().NoError(err)
+
+		ok, err := licenses[0].VerifyPrepared(pk)
+		s.Require().NoError(err)
+		s.Require().True(ok)
+	})
+}
+
+func benchmarkLicenses(b *testing.B, n int) (*lk.PrivateKey, []*lk.License) {
+	k, err := lk.NewPrivateKey()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	licenses := make([]*lk.License, n)
+	for i := range licenses {
+		data := make([]byte, 200)
+		if _, err := rand.Read(data); err != nil {
+			b.Fatal(err)
+		}
+		if licenses[i], err = lk.NewLicense(k, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return k, licenses
+}
+
+func BenchmarkVerify(b *testing.B) {
+	k, licenses := benchmarkLicenses(b, 100)
+	pub := k.GetPublicKey()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, l := range licenses {
+			if _, err := l.Verify(pub); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkVerifyPrepared(b *testing.B) {
+	k, licenses := benchmarkLicenses(b, 100)
+	pk, err := k.GetPublicKey().Prepare()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, l := range licenses {
+			if _, err := l.VerifyPrepared(pk); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkBatchVerify(b *testing.B) {
+	k, licenses := benchmarkLicenses(b, 100)
+	v, err := lk.NewBatchVerifier(k.GetPublicKey(), 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := v.Verify(context.Background(), licenses); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSign(b *testing.B) {
+	k, licenses := benchmarkLicenses(b, 1)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 100; j++ {
+			if _, err := lk.NewLicense(k, licenses[0].Data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkBatchSign(b *testing.B) {
+	k, licenses := benchmarkLicenses(b, 1)
+	data := make([][]byte, 100)
+	for i := range data {
+		data[i] = licenses[0].Data
+	}
+	s := lk.NewBatchSigner(k, 0)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := s.Sign(context.Background(), data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package lk
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/sm3"
+)
+
+var (
+	// ErrProductNotFound is returned when a bundle has no entry for the
+	// requested product.
+	ErrProductNotFound = errors.New("lk: product not found in bundle")
+
+	// ErrDuplicateProduct is returned when a bundle is created with the same
+	// product id more than once.
+	ErrDuplicateProduct = errors.New("lk: duplicate product in bundle")
+)
+
+// BundleEntry is the license data of a single product inside a Bundle.
+type BundleEntry struct {
+	Product string
+	Data    []byte
+}
+
+// Bundle holds the licenses of several products under a single SM2
+// signature.
+type Bundle struct {
+	Entries []BundleEntry
+	R       *big.Int
+	S       *big.Int
+}
+
+// NewBundle creates a new bundle from the entries and signs it using SM2.
+// The entries are sorted by product id.
+func NewBundle(k Signer, entries []BundleEntry) (*Bundle, error) {
+	b := &Bundle{
+		Entries: make([]BundleEntry, len(entries)),
+	}
+	copy(b.Entries, entries)
+	sort.Slice(b.Entries, func(i, j int) bool {
+		return b.Entries[i].Product < b.Entries[j].Product
+	})
+
+	for i := 1; i < len(b.Entries); i++ {
+		if b.Entries[i].Product == b.Entries[i-1].Product {
+			return nil, ErrDuplicateProduct
+		}
+	}
+
+	if h, err := b.hash(); err != nil {
+		return nil, err
+	} else if r, s, err := k.SignMessage(h); err != nil {
+		return nil, err
+	} else {
+		b.R = r
+		b.S = s
+	}
+	return b, nil
+}
+
+// hash computes the SM3 digest of the entries. Each product id and data is
+// length prefixed so that entries can't be shifted into one another, and a
+// context string first keeps a license signed with the same key from being
+// taken for a bundle.
+func (b *Bundle) hash() ([]byte, error) {
+	h := sm3.New()
+
+	var n [4]byte
+	write := func(p []byte) error {
+		binary.BigEndian.PutUint32(n[:], uint32(len(p)))
+		if _, err := h.Write(n[:]); err != nil {
+			return err
+		}
+		_, err := h.Write(p)
+		return err
+	}
+
+	if err := write([]byte("gmsm-lk/bundle/v1")); err != nil {
+		return nil, err
+	}
+	for _, e := range b.Entries {
+		if err := write([]byte(e.Product)); err != nil {
+			return nil, err
+		}
+		if err := write(e.Data); err != nil {
+			return nil, err
+		}
+	}
+	return h.Sum(nil), nil
+}
+
+// Verify the Bundle with the public key using SM2.
+func (b *Bundle) Verify(k *PublicKey) (bool, error) {
+	if err := checkSignature(b.R, b.S); err != nil {
+		return false, err
+	}
+
+	h, err := b.hash()
+	if err != nil {
+		return false, err
+	}
+
+	pub, err := sm2.NewPublicKey(k.ToBytes())
+	if err != nil {
+		return false, err
+	}
+
+	return sm2.VerifyWithSM2(pub, nil, h, b.R, b.S), nil
+}
+
+// Products returns the product ids contained in the bundle.
+func (b *Bundle) Products() []string {
+	res := make([]string, len(b.Entries))
+	for i, e := range b.Entries {
+		res[i] = e.Product
+	}
+	return res
+}
+
+// Entry returns the entry of a product without checking the signature.
+func (b *Bundle) Entry(product string) (*BundleEntry, error) {
+	for i := range b.Entries {
+		if b.Entries[i].Product == product {
+			return &b.Entries[i], nil
+		}
+	}
+	return nil, ErrProductNotFound
+}
+
+// VerifyProduct verifies the bundle with the public key and returns the data
+// of the requested product.
+func (b *Bundle) VerifyProduct(k *PublicKey, product string) ([]byte, error) {
+	e, err := b.Entry(product)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok, err := b.Verify(k); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ErrBadSignature
+	}
+	return e.Data, nil
+}
+
+// ToBytes transforms the bundle to a []byte.
+func (b *Bundle) ToBytes() ([]byte, error) {
+	return toBytes(b)
+}
+
+// ToB64String transforms the bundle to a base64 string.
+func (b *Bundle) ToB64String() (string, error) {
+	return toB64String(b)
+}
+
+// ToB32String transforms the bundle to a base32 string.
+func (b *Bundle) ToB32String() (string, error) {
+	return toB32String(b)
+}
+
+// ToHexString transforms the bundle to a hexadecimal string.
+func (b *Bundle) ToHexString() (string, error) {
+	return toHexString(b)
+}
+
+// BundleFromBytes returns a Bundle from a []byte.
+func BundleFromBytes(b []byte) (*Bundle, error) {
+	bl := &Bundle{}
+	return bl, fromBytes(bl, b)
+}
+
+// BundleFromB64String returns a Bundle from a base64 encoded string.
+func BundleFromB64String(str string) (*Bundle, error) {
+	bl := &Bundle{}
+	return bl, fromB64String(bl, str)
+}
+
+// BundleFromB32String returns a Bundle from a base32 encoded string.
+func BundleFromB32String(str string) (*Bundle, error) {
+	bl := &Bundle{}
+	return bl, fromB32String(bl, str)
+}
+
+// BundleFromHexString returns a Bundle from a hexadecimal encoded string.
+func BundleFromHexString(str string) (*Bundle, error) {
+	bl := &Bundle{}
+	return bl, fromHexString(bl, str)
+}
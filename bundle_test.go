@@ -0,0 +1,80 @@
+package lk_test
+
+import (
+	"encoding/binary"
+
+	lk "github.com/phox/gmsm-lk"
+)
+
+func (s *Suite) TestBundle() {
+	privateKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+	wrongKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+
+	entries := []lk.BundleEntry{
+		{Product: "editor", Data: s.RandomBytes(50)},
+		{Product: "analyzer", Data: s.RandomBytes(50)},
+	}
+
+	bundle, err := lk.NewBundle(privateKey, entries)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"analyzer", "editor"}, bundle.Products())
+
+	s.Run("should verify a product", func() {
+		data, err := bundle.VerifyProduct(privateKey.GetPublicKey(), "editor")
+		s.Require().NoError(err)
+		s.Require().Equal(entries[0].Data, data)
+
+		_, err = bundle.VerifyProduct(privateKey.GetPublicKey(), "missing")
+		s.Require().ErrorIs(err, lk.ErrProductNotFound)
+
+		_, err = bundle.VerifyProduct(wrongKey.GetPublicKey(), "editor")
+		s.Require().ErrorIs(err, lk.ErrBadSignature)
+	})
+
+	s.Run("should not verify a tampered bundle", func() {
+		b, err := bundle.ToBytes()
+		s.Require().NoError(err)
+		tampered, err := lk.BundleFromBytes(b)
+		s.Require().NoError(err)
+
+		tampered.Entries[0].Product = "other"
+		ok, err := tampered.Verify(privateKey.GetPublicKey())
+		s.Require().NoError(err)
+		s.Require().False(ok)
+	})
+
+	s.Run("should not verify a license as a bundle", func() {
+		// a license whose data is the length prefixed encoding of a bundle
+		var data []byte
+		for _, p := range [][]byte{[]byte("editor"), []byte("data")} {
+			data = binary.BigEndian.AppendUint32(data, uint32(len(p)))
+			data = append(data, p...)
+		}
+		l, err := lk.NewLicense(privateKey, data)
+		s.Require().NoError(err)
+
+		forged := &lk.Bundle{Entries: []lk.BundleEntry{{Product: "editor", Data: []byte("data")}}, R: l.R, S: l.S}
+		ok, err := forged.Verify(privateKey.GetPublicKey())
+		s.Require().NoError(err)
+		s.Require().False(ok)
+	})
+
+	s.Run("should reject duplicate products", func() {
+		_, err := lk.NewBundle(privateKey, append(entries, entries[0]))
+		s.Require().ErrorIs(err, lk.ErrDuplicateProduct)
+	})
+
+	s.Run("should test a bundle with b32", func() {
+		str, err := bundle.ToB32String()
+		s.Require().NoError(err)
+
+		b2, err := lk.BundleFromB32String(str)
+		s.Require().NoError(err)
+
+		ok, err := b2.Verify(privateKey.GetPublicKey())
+		s.Require().NoError(err)
+		s.Require().True(ok)
+	})
+}
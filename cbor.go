@@ -0,0 +1,305 @@
+package lk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// errCBOR is returned when a CBOR document can't be decoded.
+var errCBOR = fmt.Errorf("%w: invalid cbor", ErrMalformed)
+
+const (
+	cborUint   = 0
+	cborNegInt = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+
+	// cborMaxDepth limits the nesting of decoded arrays and maps.
+	cborMaxDepth = 16
+)
+
+// cborTagged is a CBOR tagged value.
+type cborTagged struct {
+	Tag   uint64
+	Value interface{}
+}
+
+// cborEncoder is a minimal deterministic CBOR (RFC 8949) encoder. Map keys
+// are sorted by their encoding as in the core deterministic encoding.
+type cborEncoder struct {
+	buf bytes.Buffer
+}
+
+func (e *cborEncoder) writeHead(major byte, n uint64) {
+	switch {
+	case n < 24:
+		e.buf.WriteByte(major<<5 | byte(n))
+	case n <= math.MaxUint8:
+		e.buf.WriteByte(major<<5 | 24)
+		e.buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		e.buf.WriteByte(major<<5 | 25)
+		_ = binary.Write(&e.buf, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		e.buf.WriteByte(major<<5 | 26)
+		_ = binary.Write(&e.buf, binary.BigEndian, uint32(n))
+	default:
+		e.buf.WriteByte(major<<5 | 27)
+		_ = binary.Write(&e.buf, binary.BigEndian, n)
+	}
+}
+
+func (e *cborEncoder) encode(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		e.buf.WriteByte(cborSimple<<5 | 22)
+	case bool:
+		if v {
+			e.buf.WriteByte(cborSimple<<5 | 21)
+		} else {
+			e.buf.WriteByte(cborSimple<<5 | 20)
+		}
+	case int:
+		return e.encode(int64(v))
+	case int64:
+		if v < 0 {
+			e.writeHead(cborNegInt, uint64(-(v + 1)))
+		} else {
+			e.writeHead(cborUint, uint64(v))
+		}
+	case uint64:
+		e.writeHead(cborUint, v)
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return e.encode(int64(v))
+		}
+		e.buf.WriteByte(cborSimple<<5 | 27)
+		_ = binary.Write(&e.buf, binary.BigEndian, math.Float64bits(v))
+	case []byte:
+		e.writeHead(cborBytes, uint64(len(v)))
+		e.buf.Write(v)
+	case string:
+		e.writeHead(cborText, uint64(len(v)))
+		e.buf.WriteString(v)
+	case []interface{}:
+		e.writeHead(cborArray, uint64(len(v)))
+		for _, item := range v {
+			if err := e.encode(item); err != nil {
+				return err
+			}
+		}
+	case []string:
+		e.writeHead(cborArray, uint64(len(v)))
+		for _, item := range v {
+			e.writeHead(cborText, uint64(len(item)))
+			e.buf.WriteString(item)
+		}
+	case map[string]interface{}:
+		m := make(map[interface{}]interface{}, len(v))
+		for k, item := range v {
+			m[k] = item
+		}
+		return e.encode(m)
+	case map[interface{}]interface{}:
+		return e.encodeMap(v)
+	case cborTagged:
+		e.writeHead(cborTag, v.Tag)
+		return e.encode(v.Value)
+	default:
+		return errCBOR
+	}
+	return nil
+}
+
+func (e *cborEncoder) encodeMap(m map[interface{}]interface{}) error {
+	type entry struct {
+		key []byte
+		val interface{}
+	}
+
+	entries := make([]entry, 0, len(m))
+	for k, v := range m {
+		ke := &cborEncoder{}
+		if err := ke.encode(k); err != nil {
+			return err
+		}
+		entries = append(entries, entry{ke.buf.Bytes(), v})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+
+	e.writeHead(cborMap, uint64(len(entries)))
+	for _, en := range entries {
+		e.buf.Write(en.key)
+		if err := e.encode(en.val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func cborMarshal(v interface{}) ([]byte, error) {
+	e := &cborEncoder{}
+	if err := e.encode(v); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+// cborDecoder decodes the subset of CBOR produced by cborEncoder. Integers
+// are returned as int64, maps as map[interface{}]interface{} with int64 or
+// string keys. Lengths are checked against the remaining input before any
+// allocation.
+type cborDecoder struct {
+	b []byte
+}
+
+func (d *cborDecoder) readHead() (byte, uint64, error) {
+	if len(d.b) < 1 {
+		return 0, 0, errCBOR
+	}
+	major, info := d.b[0]>>5, d.b[0]&0x1f
+	d.b = d.b[1:]
+
+	var size int
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, 0, errCBOR
+	}
+	if len(d.b) < size {
+		return 0, 0, errCBOR
+	}
+
+	var n uint64
+	for _, c := range d.b[:size] {
+		n = n<<8 | uint64(c)
+	}
+	d.b = d.b[size:]
+	return major, n, nil
+}
+
+func (d *cborDecoder) decode(depth int) (interface{}, error) {
+	if depth > cborMaxDepth {
+		return nil, errCBOR
+	}
+
+	if len(d.b) > 0 && d.b[0] == cborSimple<<5|27 {
+		if len(d.b) < 9 {
+			return nil, errCBOR
+		}
+		f := math.Float64frombits(binary.BigEndian.Uint64(d.b[1:9]))
+		d.b = d.b[9:]
+		return f, nil
+	}
+
+	major, n, err := d.readHead()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborUint:
+		if n > math.MaxInt64 {
+			return nil, errCBOR
+		}
+		return int64(n), nil
+	case cborNegInt:
+		if n > math.MaxInt64 {
+			return nil, errCBOR
+		}
+		return -int64(n) - 1, nil
+	case cborBytes, cborText:
+		if n > uint64(len(d.b)) {
+			return nil, errCBOR
+		}
+		b := d.b[:n]
+		d.b = d.b[n:]
+		if major == cborText {
+			return string(b), nil
+		}
+		return append([]byte{}, b...), nil
+	case cborArray:
+		if n > uint64(len(d.b)) {
+			return nil, errCBOR
+		}
+		a := make([]interface{}, 0, n)
+		for i := uint64(0); i < n; i++ {
+			v, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		return a, nil
+	case cborMap:
+		if n > uint64(len(d.b))/2 {
+			return nil, errCBOR
+		}
+		m := make(map[interface{}]interface{}, n)
+		for i := uint64(0); i < n; i++ {
+			k, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, errCBOR
+			}
+			if _, ok := m[k]; ok {
+				return nil, errCBOR
+			}
+			if m[k], err = d.decode(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case cborTag:
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return cborTagged{Tag: n, Value: v}, nil
+	case cborSimple:
+		switch n {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22:
+			return nil, nil
+		}
+	}
+	return nil, errCBOR
+}
+
+// cborUnmarshal decodes a single CBOR item and rejects trailing data.
+func cborUnmarshal(b []byte) (interface{}, error) {
+	d := &cborDecoder{b: b}
+	v, err := d.decode(0)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.b) != 0 {
+		return nil, errCBOR
+	}
+	return v, nil
+}
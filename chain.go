@@ -0,0 +1,233 @@
+package lk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/emmansun/gmsm/sm3"
+)
+
+var (
+	// ErrBrokenChain is returned when a license doesn't supersede the
+	// previous license of a chain.
+	ErrBrokenChain = errors.New("lk: broken license chain")
+
+	// ErrSuperseded is returned when a license was replaced by a newer
+	// license.
+	ErrSuperseded = errors.New("lk: license superseded")
+)
+
+// Reference identifies a previous license by its serial and its SM3 digest.
+type Reference struct {
+	Serial string `json:"serial"`
+	Hash   []byte `json:"hash"`
+}
+
+// Digest returns the SM3 digest of the license data and signature.
+func (l *License) Digest() ([]byte, error) {
+	if err := checkSignature(l.R, l.S); err != nil {
+		return nil, err
+	}
+
+	h := sm3.New()
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(l.Data)))
+	if _, err := h.Write(n[:]); err != nil {
+		return nil, err
+	}
+	if _, err := h.Write(l.Data); err != nil {
+		return nil, err
+	}
+
+	rs := make([]byte, 64)
+	l.R.FillBytes(rs[:32])
+	l.S.FillBytes(rs[32:])
+	if _, err := h.Write(rs); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// Reference returns the reference of the license to be used in the claims of
+// the license that supersedes it.
+func (l *License) Reference() (*Reference, error) {
+	c, err := l.Claims()
+	if err != nil {
+		return nil, err
+	}
+	h, err := l.Digest()
+	if err != nil {
+		return nil, err
+	}
+	return &Reference{Serial: c.Serial, Hash: h}, nil
+}
+
+// NewRenewal creates a license that supersedes prev.
+func NewRenewal(k Signer, prev *License, c *Claims) (*License, error) {
+	ref, err := prev.Reference()
+	if err != nil {
+		return nil, err
+	}
+	c.Supersedes = ref
+	return NewClaimsLicense(k, c)
+}
+
+// Supersedes tells if the license is the direct successor of prev.
+func (l *License) Supersedes(prev *License) (bool, error) {
+	c, err := l.Claims()
+	if err != nil {
+		return false, err
+	}
+	if c.Supersedes == nil {
+		return false, nil
+	}
+
+	ref, err := prev.Reference()
+	if err != nil {
+		return false, err
+	}
+	return c.Supersedes.Serial == ref.Serial && bytes.Equal(c.Supersedes.Hash, ref.Hash), nil
+}
+
+// VerifyChain verifies the signature of every license of the chain and
+// checks that each license supersedes the previous one. The chain is ordered
+// from the oldest to the newest license.
+func VerifyChain(k *PublicKey, chain []*License) error {
+	for i, l := range chain {
+		if ok, err := l.Verify(k); err != nil {
+			return err
+		} else if !ok {
+			return ErrBadSignature
+		}
+
+		if i == 0 {
+			continue
+		}
+		if ok, err := l.Supersedes(chain[i-1]); err != nil {
+			return err
+		} else if !ok {
+			return ErrBrokenChain
+		}
+	}
+	return nil
+}
+
+// WalkChain follows the supersedes references from head using lookup and
+// returns the chain ordered from the oldest to the newest license. The
+// licenses are not verified, use VerifyChain for that.
+func WalkChain(head *License, lookup func(ref Reference) (*License, error)) ([]*License, error) {
+	chain := []*License{head}
+	seen := map[string]bool{}
+
+	for l := head; ; {
+		c, err := l.Claims()
+		if err != nil {
+			return nil, err
+		}
+		if c.Supersedes == nil {
+			break
+		}
+
+		id := hex.EncodeToString(c.Supersedes.Hash)
+		if seen[id] {
+			return nil, ErrBrokenChain
+		}
+		seen[id] = true
+
+		if l, err = lookup(*c.Supersedes); err != nil {
+			return nil, err
+		}
+		if l == nil {
+			return nil, fmt.Errorf("%w: %s not found", ErrBrokenChain, c.Supersedes.Serial)
+		}
+		chain = append(chain, l)
+	}
+
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain, nil
+}
+
+// Tracker remembers the licenses superseded by the licenses seen locally, so
+// that an old license can't be presented again once it was renewed.
+type Tracker struct {
+	mu         sync.Mutex
+	path       string
+	superseded map[string]string
+}
+
+// NewTracker returns an in memory Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{superseded: map[string]string{}}
+}
+
+// OpenTracker returns a Tracker persisted in a json file. The file is
+// created on the first Observe if it doesn't exist.
+func OpenTracker(path string) (*Tracker, error) {
+	t := NewTracker()
+	t.path = path
+
+	b, err := os.ReadFile(path) // #nosec G304 -- path is chosen by the application
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &t.superseded); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Observe records the license superseded by l. The license should have been
+// verified before.
+func (t *Tracker) Observe(l *License) error {
+	c, err := l.Claims()
+	if err != nil {
+		return err
+	}
+	if c.Supersedes == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	id := hex.EncodeToString(c.Supersedes.Hash)
+	if _, ok := t.superseded[id]; ok {
+		return nil
+	}
+	t.superseded[id] = c.Serial
+
+	if t.path == "" {
+		return nil
+	}
+	b, err := json.Marshal(t.superseded)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(t.path, b)
+}
+
+// Check returns ErrSuperseded if a newer license superseding l was observed.
+func (t *Tracker) Check(l *License) error {
+	h, err := l.Digest()
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.superseded[hex.EncodeToString(h)]; ok {
+		return ErrSuperseded
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package lk_test
+
+import (
+	"path/filepath"
+
+	lk "github.com/phox/gmsm-lk"
+)
+
+func (s *Suite) TestChain() {
+	privateKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+	publicKey := privateKey.GetPublicKey()
+
+	first, err := lk.NewClaimsLicense(privateKey, &lk.Claims{Subject: "customer"})
+	s.Require().NoError(err)
+	second, err := lk.NewRenewal(privateKey, first, &lk.Claims{Subject: "customer"})
+	s.Require().NoError(err)
+	third, err := lk.NewRenewal(privateKey, second, &lk.Claims{Subject: "customer"})
+	s.Require().NoError(err)
+
+	s.Run("should verify a chain", func() {
+		s.Require().NoError(lk.VerifyChain(publicKey, []*lk.License{first, second, third}))
+		s.Require().ErrorIs(lk.VerifyChain(publicKey, []*lk.License{first, third}), lk.ErrBrokenChain)
+
+		ok, err := second.Supersedes(first)
+		s.Require().NoError(err)
+		s.Require().True(ok)
+	})
+
+	s.Run("should walk a chain", func() {
+		byHash := map[string]*lk.License{}
+		for _, l := range []*lk.License{first, second} {
+			h, err := l.Digest()
+			s.Require().NoError(err)
+			byHash[string(h)] = l
+		}
+
+		chain, err := lk.WalkChain(third, func(ref lk.Reference) (*lk.License, error) {
+			return byHash[string(ref.Hash)], nil
+		})
+		s.Require().NoError(err)
+		s.Require().Equal([]*lk.License{first, second, third}, chain)
+
+		h, err := first.Digest()
+		s.Require().NoError(err)
+		delete(byHash, string(h))
+		_, err = lk.WalkChain(third, func(ref lk.Reference) (*lk.License, error) {
+			return byHash[string(ref.Hash)], nil
+		})
+		s.Require().ErrorIs(err, lk.ErrBrokenChain)
+	})
+
+	s.Run("should flag superseded licenses", func() {
+		path := filepath.Join(s.T().TempDir(), "tracker.json")
+		t, err := lk.OpenTracker(path)
+		s.Require().NoError(err)
+
+		s.Require().NoError(t.Check(first))
+		s.Require().NoError(t.Observe(second))
+		s.Require().ErrorIs(t.Check(first), lk.ErrSuperseded)
+		s.Require().NoError(t.Check(second))
+
+		t2, err := lk.OpenTracker(path)
+		s.Require().NoError(err)
+		s.Require().ErrorIs(t2.Check(first), lk.ErrSuperseded)
+	})
+
+	s.Run("should reject superseded licenses on verification", func() {
+		opts := lk.VerifyOptions{Tracker: lk.NewTracker()}
+		_, err := first.VerifyClaims(publicKey, opts)
+		s.Require().NoError(err)
+		_, err = second.VerifyClaims(publicKey, opts)
+		s.Require().NoError(err)
+		_, err = first.VerifyClaims(publicKey, opts)
+		s.Require().ErrorIs(err, lk.ErrSuperseded)
+	})
+}
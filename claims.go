@@ -0,0 +1,263 @@
+package lk
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/mail"
+	"time"
+)
+
+// The optional claims of Extra checked by CheckSchema.
+const (
+	// ClaimEmail is the email address of the licensee.
+	ClaimEmail = "email"
+	// ClaimFeatures is the list of the enabled features.
+	ClaimFeatures = "features"
+	// ClaimQuotas maps the names of the quotas to their limits.
+	ClaimQuotas = "quotas"
+)
+
+// ErrInvalidClaims is returned by CheckSchema when the claims are not
+// consistent.
+var ErrInvalidClaims = errors.New("lk: invalid claims")
+
+// Claims is a standard license document. It is marshalled to json and
+// stored as the Data of a License. Fields that are not known by the library
+// are kept in Extra.
+type Claims struct {
+	Serial     string
+	Subject    string
+	Product    string
+	Machine    string
+	IssuedAt   time.Time
+	NotBefore  time.Time
+	Expires    time.Time
+	Supersedes *Reference
+	Extra      map[string]interface{}
+}
+
+// claimsJSON is the json representation of Claims, the pointers are used to
+// omit the zero times.
+type claimsJSON struct {
+	Serial     string     `json:"serial,omitempty"`
+	Subject    string     `json:"subject,omitempty"`
+	Product    string     `json:"product,omitempty"`
+	Machine    string     `json:"machine,omitempty"`
+	IssuedAt   *time.Time `json:"issued_at,omitempty"`
+	NotBefore  *time.Time `json:"not_before,omitempty"`
+	Expires    *time.Time `json:"expires,omitempty"`
+	Supersedes *Reference `json:"supersedes,omitempty"`
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func timeVal(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c Claims) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(claimsJSON{
+		Serial:     c.Serial,
+		Subject:    c.Subject,
+		Product:    c.Product,
+		Machine:    c.Machine,
+		IssuedAt:   timePtr(c.IssuedAt),
+		NotBefore:  timePtr(c.NotBefore),
+		Expires:    timePtr(c.Expires),
+		Supersedes: c.Supersedes,
+	})
+	if err != nil || len(c.Extra) == 0 {
+		return b, err
+	}
+
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	for k, v := range c.Extra {
+		if _, ok := m[k]; !ok {
+			m[k] = v
+		}
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *Claims) UnmarshalJSON(b []byte) error {
+	var cj claimsJSON
+	if err := json.Unmarshal(b, &cj); err != nil {
+		return err
+	}
+
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	for _, k := range []string{
+		"serial", "subject", "product", "machine", "issued_at", "not_before", "expires", "supersedes",
+	} {
+		delete(m, k)
+	}
+	if len(m) == 0 {
+		m = nil
+	}
+
+	*c = Claims{
+		Serial:     cj.Serial,
+		Subject:    cj.Subject,
+		Product:    cj.Product,
+		Machine:    cj.Machine,
+		IssuedAt:   timeVal(cj.IssuedAt),
+		NotBefore:  timeVal(cj.NotBefore),
+		Expires:    timeVal(cj.Expires),
+		Supersedes: cj.Supersedes,
+		Extra:      m,
+	}
+	return nil
+}
+
+// NewSerial returns a new random license serial.
+func NewSerial() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewClaimsLicense creates a new license with the claims as data. A serial
+// and the issue time are set if they are missing.
+func NewClaimsLicense(k Signer, c *Claims) (*License, error) {
+	if c.Serial == "" {
+		serial, err := NewSerial()
+		if err != nil {
+			return nil, err
+		}
+		c.Serial = serial
+	}
+	if c.IssuedAt.IsZero() {
+		c.IssuedAt = time.Now().UTC().Truncate(time.Second)
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return NewLicense(k, data)
+}
+
+// Claims unmarshals the data of the license. It doesn't verify the
+// signature.
+func (l *License) Claims() (*Claims, error) {
+	c := &Claims{}
+	if err := json.Unmarshal(l.Data, c); err != nil {
+		return nil, &DecodeError{Encoding: "json", Err: err}
+	}
+	return c, nil
+}
+
+// Valid checks the validity period of the claims at the given time. It
+// returns a *ValidityError matching ErrExpired or ErrNotYetValid.
+func (c *Claims) Valid(now time.Time) error {
+	if !c.NotBefore.IsZero() && now.Before(c.NotBefore) {
+		return &ValidityError{Err: ErrNotYetValid, Date: c.NotBefore, Now: now}
+	}
+	if !c.Expires.IsZero() && !now.Before(c.Expires) {
+		return &ValidityError{Err: ErrExpired, Date: c.Expires, Now: now}
+	}
+	return nil
+}
+
+// CheckMachine returns ErrWrongMachine if the claims are bound to another
+// machine. Claims without a machine are valid on any machine.
+func (c *Claims) CheckMachine(machine string) error {
+	if c.Machine != "" && c.Machine != machine {
+		return fmt.Errorf("%w: %s", ErrWrongMachine, machine)
+	}
+	return nil
+}
+
+// CheckSchema checks that the claims are consistent before they are signed:
+// the validity period must not be empty, the email must be an address, the
+// features a list of distinct names and the quotas non negative integers.
+func (c *Claims) CheckSchema() error {
+	if !c.Expires.IsZero() {
+		if !c.NotBefore.IsZero() && !c.NotBefore.Before(c.Expires) {
+			return fmt.Errorf("%w: expires is not after not_before", ErrInvalidClaims)
+		}
+		if !c.IssuedAt.IsZero() && !c.IssuedAt.Before(c.Expires) {
+			return fmt.Errorf("%w: expires is not after issued_at", ErrInvalidClaims)
+		}
+	}
+
+	if v, ok := c.Extra[ClaimEmail]; ok {
+		email, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%w: email is not a string", ErrInvalidClaims)
+		}
+		if a, err := mail.ParseAddress(email); err != nil || a.Address != email {
+			return fmt.Errorf("%w: invalid email %q", ErrInvalidClaims, email)
+		}
+	}
+
+	if v, ok := c.Extra[ClaimFeatures]; ok {
+		features, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%w: features is not a list", ErrInvalidClaims)
+		}
+		seen := map[string]bool{}
+		for _, f := range features {
+			name, ok := f.(string)
+			if !ok || name == "" {
+				return fmt.Errorf("%w: invalid feature %v", ErrInvalidClaims, f)
+			}
+			if seen[name] {
+				return fmt.Errorf("%w: duplicate feature %q", ErrInvalidClaims, name)
+			}
+			seen[name] = true
+		}
+	}
+
+	if v, ok := c.Extra[ClaimQuotas]; ok {
+		quotas, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%w: quotas is not an object", ErrInvalidClaims)
+		}
+		for name, q := range quotas {
+			if !isCount(q) {
+				return fmt.Errorf("%w: quota %q is not a non negative integer", ErrInvalidClaims, name)
+			}
+		}
+	}
+	return nil
+}
+
+// isCount tells if v is a non negative integer, as decoded from json or set
+// by the application.
+func isCount(v interface{}) bool {
+	switch n := v.(type) {
+	case float64:
+		return n >= 0 && n == math.Trunc(n) && !math.IsInf(n, 0)
+	case int:
+		return n >= 0
+	case int64:
+		return n >= 0
+	case json.Number:
+		i, err := n.Int64()
+		return err == nil && i >= 0
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package lk_test
+
+import (
+	"encoding/json"
+	"time"
+
+	lk "github.com/phox/gmsm-lk"
+)
+
+func (s *Suite) TestClaims() {
+	privateKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+
+	s.Run("should create a claims license", func() {
+		c := &lk.Claims{
+			Subject: "user@example.com",
+			Product: "editor",
+			Expires: time.Now().Add(time.Hour).UTC().Truncate(time.Second),
+		}
+		l, err := lk.NewClaimsLicense(privateKey, c)
+		s.Require().NoError(err)
+		s.Require().NotEmpty(c.Serial)
+		s.Require().False(c.IssuedAt.IsZero())
+
+		c2, err := l.Claims()
+		s.Require().NoError(err)
+		s.Require().Equal(c.Serial, c2.Serial)
+		s.Require().Equal(c.Subject, c2.Subject)
+		s.Require().True(c.Expires.Equal(c2.Expires))
+		s.Require().True(c2.NotBefore.IsZero())
+	})
+
+	s.Run("should keep unknown fields", func() {
+		c := &lk.Claims{}
+		s.Require().NoError(json.Unmarshal([]byte(`{"serial":"1","seats":5}`), c))
+		s.Require().Equal("1", c.Serial)
+		s.Require().Equal(map[string]interface{}{"seats": float64(5)}, c.Extra)
+
+		b, err := json.Marshal(c)
+		s.Require().NoError(err)
+		s.Require().JSONEq(`{"serial":"1","seats":5}`, string(b))
+	})
+
+	s.Run("should check the schema", func() {
+		now := time.Now()
+		valid := &lk.Claims{
+			NotBefore: now,
+			Expires:   now.Add(time.Hour),
+			Extra: map[string]interface{}{
+				lk.ClaimEmail:    "user@example.com",
+				lk.ClaimFeatures: []interface{}{"export", "sync"},
+				lk.ClaimQuotas:   map[string]interface{}{"users": float64(50), "seats": 3},
+			},
+		}
+		s.Require().NoError(valid.CheckSchema())
+
+		for _, c := range []*lk.Claims{
+			{NotBefore: now, Expires: now},
+			{IssuedAt: now, Expires: now.Add(-time.Hour)},
+			{Extra: map[string]interface{}{lk.ClaimEmail: "user"}},
+			{Extra: map[string]interface{}{lk.ClaimEmail: "User <user@example.com>"}},
+			{Extra: map[string]interface{}{lk.ClaimFeatures: "export"}},
+			{Extra: map[string]interface{}{lk.ClaimFeatures: []interface{}{"a", "a"}}},
+			{Extra: map[string]interface{}{lk.ClaimQuotas: map[string]interface{}{"users": 1.5}}},
+			{Extra: map[string]interface{}{lk.ClaimQuotas: map[string]interface{}{"users": -1}}},
+		} {
+			s.Require().ErrorIs(c.CheckSchema(), lk.ErrInvalidClaims)
+		}
+	})
+}
@@ -0,0 +1,256 @@
+package lk
+
+import (
+	"encoding/hex"
+	"math/big"
+	"time"
+
+	"github.com/emmansun/gmsm/sm2"
+)
+
+// COSEAlgSM2SM3 is the COSE algorithm identifier of SM2 signatures over
+// SM3. SM2 has no registered COSE identifier, a value of the private use
+// range is used.
+const COSEAlgSM2SM3 = -65601
+
+const (
+	coseTagSign1 = 18
+
+	coseHeaderAlg = 1
+	coseHeaderKid = 4
+
+	cwtSubject    = 2
+	cwtExpires    = 4
+	cwtNotBefore  = 5
+	cwtIssuedAt   = 6
+	cwtSerial     = 7
+	cwtProduct    = -65537
+	cwtSupersedes = -65538
+	cwtMachine    = -65539
+)
+
+// coseSigStructure returns the Sig_structure signed by a COSE_Sign1.
+func coseSigStructure(protected, payload []byte) ([]byte, error) {
+	return cborMarshal([]interface{}{"Signature1", protected, []byte{}, payload})
+}
+
+// NewCWT signs the claims as a CBOR COSE_Sign1 structure (RFC 9052) with
+// integer keyed claims (RFC 8392). The claims that are not defined by the
+// CWT specification use keys of the private use range and the Extra claims
+// keep their names.
+func NewCWT(k Signer, c *Claims) ([]byte, error) {
+	claims := map[interface{}]interface{}{}
+	for name, v := range c.Extra {
+		claims[name] = v
+	}
+	if c.Subject != "" {
+		claims[int64(cwtSubject)] = c.Subject
+	}
+	if c.Serial != "" {
+		claims[int64(cwtSerial)] = []byte(c.Serial)
+	}
+	if c.Product != "" {
+		claims[int64(cwtProduct)] = c.Product
+	}
+	if c.Machine != "" {
+		claims[int64(cwtMachine)] = c.Machine
+	}
+	for key, t := range map[int64]time.Time{
+		cwtExpires:   c.Expires,
+		cwtNotBefore: c.NotBefore,
+		cwtIssuedAt:  c.IssuedAt,
+	} {
+		if !t.IsZero() {
+			claims[key] = t.Unix()
+		}
+	}
+	if c.Supersedes != nil {
+		claims[int64(cwtSupersedes)] = []interface{}{c.Supersedes.Serial, c.Supersedes.Hash}
+	}
+
+	payload, err := cborMarshal(claims)
+	if err != nil {
+		return nil, err
+	}
+	kid, err := hex.DecodeString(k.GetPublicKey().Fingerprint())
+	if err != nil {
+		return nil, err
+	}
+	protected, err := cborMarshal(map[interface{}]interface{}{
+		int64(coseHeaderAlg): int64(COSEAlgSM2SM3),
+		int64(coseHeaderKid): kid,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tbs, err := coseSigStructure(protected, payload)
+	if err != nil {
+		return nil, err
+	}
+	r, s, err := k.SignMessage(tbs)
+	if err != nil {
+		return nil, err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+
+	return cborMarshal(cborTagged{
+		Tag:   coseTagSign1,
+		Value: []interface{}{protected, map[interface{}]interface{}{}, payload, sig},
+	})
+}
+
+// ToCWT signs the claims of the license as a COSE_Sign1 structure. The
+// license data must be Claims.
+func (l *License) ToCWT(k Signer) ([]byte, error) {
+	c, err := l.Claims()
+	if err != nil {
+		return nil, err
+	}
+	return NewCWT(k, c)
+}
+
+// ParseCWT verifies a COSE_Sign1 structure created by NewCWT and returns its
+// claims. The algorithm must be COSEAlgSM2SM3 and the key id must match the
+// public key. The validity dates are not checked.
+func ParseCWT(b []byte, k *PublicKey) (*Claims, error) {
+	v, err := cborUnmarshal(b)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if t, ok := v.(cborTagged); ok && t.Tag == coseTagSign1 {
+		v = t.Value
+	}
+	msg, ok := v.([]interface{})
+	if !ok || len(msg) != 4 {
+		return nil, ErrInvalidToken
+	}
+	protected, ok1 := msg[0].([]byte)
+	payload, ok2 := msg[2].([]byte)
+	sig, ok3 := msg[3].([]byte)
+	if !ok1 || !ok2 || !ok3 || len(sig) != 64 {
+		return nil, ErrInvalidToken
+	}
+
+	hv, err := cborUnmarshal(protected)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	header, ok := hv.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	if alg, _ := header[int64(coseHeaderAlg)].(int64); alg != COSEAlgSM2SM3 {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	if kid, _ := header[int64(coseHeaderKid)].([]byte); hex.EncodeToString(kid) != k.Fingerprint() {
+		return nil, ErrUnknownKey
+	}
+
+	tbs, err := coseSigStructure(protected, payload)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := sm2.NewPublicKey(k.ToBytes())
+	if err != nil {
+		return nil, err
+	}
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	if !sm2.VerifyWithSM2(pub, nil, tbs, r, s) {
+		return nil, ErrBadSignature
+	}
+
+	cv, err := cborUnmarshal(payload)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	claims, ok := cv.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return cwtClaims(claims)
+}
+
+func cwtClaims(m map[interface{}]interface{}) (*Claims, error) {
+	c := &Claims{}
+	for key, v := range m {
+		switch key := key.(type) {
+		case string:
+			if c.Extra == nil {
+				c.Extra = map[string]interface{}{}
+			}
+			c.Extra[key] = cborToJSON(v)
+		case int64:
+			var ok bool
+			switch key {
+			case cwtSubject:
+				c.Subject, ok = v.(string)
+			case cwtProduct:
+				c.Product, ok = v.(string)
+			case cwtMachine:
+				c.Machine, ok = v.(string)
+			case cwtSerial:
+				var b []byte
+				b, ok = v.([]byte)
+				c.Serial = string(b)
+			case cwtExpires, cwtNotBefore, cwtIssuedAt:
+				var sec int64
+				sec, ok = v.(int64)
+				t := time.Unix(sec, 0).UTC()
+				switch key {
+				case cwtExpires:
+					c.Expires = t
+				case cwtNotBefore:
+					c.NotBefore = t
+				default:
+					c.IssuedAt = t
+				}
+			case cwtSupersedes:
+				a, _ := v.([]interface{})
+				if len(a) == 2 {
+					ref := &Reference{}
+					var ok2 bool
+					ref.Serial, ok = a[0].(string)
+					ref.Hash, ok2 = a[1].([]byte)
+					ok = ok && ok2
+					c.Supersedes = ref
+				}
+			default:
+				ok = true
+			}
+			if !ok {
+				return nil, ErrInvalidToken
+			}
+		}
+	}
+	return c, nil
+}
+
+// cborToJSON converts a decoded CBOR value to the types used by
+// encoding/json so that the Extra claims compare equal after a round trip.
+func cborToJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int64:
+		return float64(v)
+	case []byte:
+		return string(v)
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			res[i] = cborToJSON(item)
+		}
+		return res
+	case map[interface{}]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			if s, ok := k.(string); ok {
+				res[s] = cborToJSON(item)
+			}
+		}
+		return res
+	}
+	return v
+}
@@ -0,0 +1,59 @@
+package lk_test
+
+import (
+	"time"
+
+	lk "github.com/phox/gmsm-lk"
+)
+
+func (s *Suite) TestCWT() {
+	privateKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+	wrongKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+
+	c := &lk.Claims{
+		Serial:     "42",
+		Subject:    "device-1",
+		Product:    "firmware",
+		IssuedAt:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Expires:    time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		Supersedes: &lk.Reference{Serial: "41", Hash: s.RandomBytes(32)},
+		Extra: map[string]interface{}{
+			"seats":    float64(5),
+			"ratio":    1.5,
+			"features": []interface{}{"a", "b"},
+			"limits":   map[string]interface{}{"cpu": float64(-2), "on": true},
+		},
+	}
+
+	b, err := lk.NewCWT(privateKey, c)
+	s.Require().NoError(err)
+	s.Require().Equal(byte(0xd2), b[0]) // tag 18
+
+	s.Run("should parse a cwt", func() {
+		c2, err := lk.ParseCWT(b, privateKey.GetPublicKey())
+		s.Require().NoError(err)
+		s.Require().Equal(c, c2)
+	})
+
+	s.Run("should reject invalid cwt", func() {
+		_, err := lk.ParseCWT(b, wrongKey.GetPublicKey())
+		s.Require().ErrorIs(err, lk.ErrUnknownKey)
+
+		_, err = lk.ParseCWT(append(b, 0), privateKey.GetPublicKey())
+		s.Require().ErrorIs(err, lk.ErrInvalidToken)
+
+		_, err = lk.ParseCWT(s.RandomBytes(100), privateKey.GetPublicKey())
+		s.Require().Error(err)
+
+		// a huge array length must not be allocated
+		_, err = lk.ParseCWT([]byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, privateKey.GetPublicKey())
+		s.Require().ErrorIs(err, lk.ErrInvalidToken)
+
+		tampered := append([]byte{}, b...)
+		tampered[len(tampered)-70] ^= 1
+		_, err = lk.ParseCWT(tampered, privateKey.GetPublicKey())
+		s.Require().Error(err)
+	})
+}
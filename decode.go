@@ -0,0 +1,171 @@
+package lk
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/emmansun/gmsm/sm2"
+)
+
+// DefaultMaxSize is the maximum size in bytes of a decoded license accepted
+// by a StrictDecoder with no MaxSize.
+const DefaultMaxSize = 64 << 10
+
+// checkSignature checks that the signature components are in [1, n-1].
+func checkSignature(r, s *big.Int) error {
+	n := sm2.P256().Params().N
+	for _, v := range []*big.Int{r, s} {
+		if v == nil {
+			return &DecodeError{Encoding: "signature", Err: errors.New("missing signature")}
+		}
+		if v.Sign() <= 0 || v.Cmp(n) >= 0 {
+			return &DecodeError{Encoding: "signature", Err: errors.New("signature out of range")}
+		}
+	}
+	return nil
+}
+
+// checkScalar checks that a private scalar is in [1, n-2] as required by
+// GB/T 32918.1-2016.
+func checkScalar(d *big.Int) error {
+	nMinus1 := new(big.Int).Sub(sm2.P256().Params().N, big.NewInt(1))
+	if d == nil || d.Sign() <= 0 || d.Cmp(nMinus1) >= 0 {
+		return &DecodeError{Encoding: "private key", Err: errors.New("scalar out of range")}
+	}
+	return nil
+}
+
+// StrictDecoder decodes licenses from untrusted inputs. The size of the input
+// is checked before it is decoded, trailing data is rejected and the
+// signature components must be in range.
+type StrictDecoder struct {
+	// MaxSize is the maximum size of the decoded license in bytes,
+	// DefaultMaxSize if zero and no limit if negative.
+	MaxSize int
+	// SkipSignatureCheck accepts signature components missing or out of
+	// range, for tools inspecting malformed licenses. License.CheckSignature
+	// reports them and the verification still rejects them.
+	SkipSignatureCheck bool
+}
+
+// checkSignature checks the signature components unless the decoder skips it.
+func (d *StrictDecoder) checkSignature(l *License) error {
+	if d.SkipSignatureCheck {
+		return nil
+	}
+	return l.CheckSignature()
+}
+
+// CheckSignature checks that the signature components are present and in
+// [1, n-1], it returns a DecodeError otherwise. The signature itself is not
+// verified.
+func (l *License) CheckSignature() error {
+	return checkSignature(l.R, l.S)
+}
+
+func (d *StrictDecoder) maxSize() int {
+	if d.MaxSize == 0 {
+		return DefaultMaxSize
+	}
+	return d.MaxSize
+}
+
+func (d *StrictDecoder) checkSize(n int) error {
+	if d.maxSize() > 0 && n > d.maxSize() {
+		return &DecodeError{
+			Encoding: "license",
+			Err:      fmt.Errorf("input of %d bytes exceeds the maximum of %d bytes", n, d.maxSize()),
+		}
+	}
+	return nil
+}
+
+// LicenseFromBytes returns a License from a []byte.
+func (d *StrictDecoder) LicenseFromBytes(b []byte) (*License, error) {
+	if err := d.checkSize(len(b)); err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer(b)
+	l := &License{}
+	if err := gob.NewDecoder(buf).Decode(l); err != nil {
+		return nil, &DecodeError{Encoding: "gob", Err: err}
+	}
+	if buf.Len() != 0 {
+		return nil, &DecodeError{Encoding: "gob", Err: errors.New("trailing data")}
+	}
+	if err := d.checkSignature(l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// LicenseFromB64String returns a License from a base64 encoded string.
+func (d *StrictDecoder) LicenseFromB64String(str string) (*License, error) {
+	if err := d.checkSize(base64.StdEncoding.DecodedLen(len(str))); err != nil {
+		return nil, err
+	}
+	b, err := decodeB64(str)
+	if err != nil {
+		return nil, err
+	}
+	return d.LicenseFromBytes(b)
+}
+
+// LicenseFromB32String returns a License from a base32 encoded string.
+func (d *StrictDecoder) LicenseFromB32String(str string) (*License, error) {
+	if err := d.checkSize(base32.StdEncoding.DecodedLen(len(str))); err != nil {
+		return nil, err
+	}
+	b, err := decodeB32(str)
+	if err != nil {
+		return nil, err
+	}
+	return d.LicenseFromBytes(b)
+}
+
+// LicenseFromHexString returns a License from a hexadecimal encoded string.
+func (d *StrictDecoder) LicenseFromHexString(str string) (*License, error) {
+	if err := d.checkSize(hex.DecodedLen(len(str))); err != nil {
+		return nil, err
+	}
+	b, err := decodeHex(str)
+	if err != nil {
+		return nil, err
+	}
+	return d.LicenseFromBytes(b)
+}
+
+// DecodeLicense returns a license in any encoding and the encoding that was
+// detected. Surrounding white spaces and line breaks are ignored.
+func (d *StrictDecoder) DecodeLicense(b []byte) (*License, Encoding, error) {
+	var l *License
+	enc, err := decodeAny(b, PEMLicense, func(raw []byte) (err error) {
+		l, err = d.LicenseFromBytes(raw)
+		return err
+	}, func(j []byte) error {
+		tmp := &License{}
+		if err := json.Unmarshal(j, tmp); err != nil {
+			return err
+		}
+		if err := d.checkSize(len(tmp.Data)); err != nil {
+			return err
+		}
+		if err := d.checkSignature(tmp); err != nil {
+			return err
+		}
+		l = tmp
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return l, enc, nil
+}
@@ -0,0 +1,112 @@
+package lk_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"strings"
+
+	lk "github.com/phox/gmsm-lk"
+)
+
+func (s *Suite) TestStrictDecoder() {
+	privateKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+
+	license, err := lk.NewLicense(privateKey, s.RandomBytes(100))
+	s.Require().NoError(err)
+	b, err := license.ToBytes()
+	s.Require().NoError(err)
+
+	d := &lk.StrictDecoder{}
+
+	s.Run("should decode a valid license", func() {
+		l, err := d.LicenseFromBytes(b)
+		s.Require().NoError(err)
+		s.Require().Equal(license, l)
+
+		str, err := license.ToB32String()
+		s.Require().NoError(err)
+		l, err = d.LicenseFromB32String(str)
+		s.Require().NoError(err)
+		s.Require().NoError(l.VerifyErr(privateKey.GetPublicKey()))
+	})
+
+	s.Run("should reject oversized inputs", func() {
+		small := &lk.StrictDecoder{MaxSize: 64}
+		_, err := small.LicenseFromBytes(b)
+		s.Require().ErrorIs(err, lk.ErrMalformed)
+
+		_, err = d.LicenseFromB64String(strings.Repeat("A", 2*lk.DefaultMaxSize))
+		s.Require().ErrorIs(err, lk.ErrMalformed)
+
+		// the package level decoders have no maximum size
+		large, err := lk.NewLicense(privateKey, s.RandomBytes(2*lk.DefaultMaxSize))
+		s.Require().NoError(err)
+		str, err := large.ToHexString()
+		s.Require().NoError(err)
+		l, err := lk.ParseLicense(str)
+		s.Require().NoError(err)
+		s.Require().NoError(l.VerifyErr(privateKey.GetPublicKey()))
+		_, _, err = d.DecodeLicense([]byte(str))
+		s.Require().ErrorIs(err, lk.ErrMalformed)
+	})
+
+	s.Run("should reject trailing data", func() {
+		_, err := d.LicenseFromBytes(append(b, 0))
+		s.Require().ErrorIs(err, lk.ErrMalformed)
+
+		_, err = lk.ParseLicense(hex.EncodeToString(append(b, 0)))
+		s.Require().ErrorIs(err, lk.ErrMalformed)
+	})
+
+	s.Run("should reject out of range signatures", func() {
+		for _, l := range []*lk.License{
+			{Data: []byte("data")},
+			{Data: []byte("data"), R: big.NewInt(0), S: big.NewInt(1)},
+			{Data: []byte("data"), R: big.NewInt(1), S: new(big.Int).Lsh(big.NewInt(1), 256)},
+		} {
+			b, err := l.ToBytes()
+			s.Require().NoError(err)
+			_, err = d.LicenseFromBytes(b)
+			s.Require().ErrorIs(err, lk.ErrMalformed)
+			_, _, err = lk.DecodeLicense([]byte(hex.EncodeToString(b)))
+			s.Require().ErrorIs(err, lk.ErrMalformed)
+
+			lenient := &lk.StrictDecoder{SkipSignatureCheck: true}
+			decoded, _, err := lenient.DecodeLicense([]byte(hex.EncodeToString(b)))
+			s.Require().NoError(err)
+			s.Require().ErrorIs(decoded.CheckSignature(), lk.ErrMalformed)
+
+			ok, err := l.Verify(privateKey.GetPublicKey())
+			s.Require().ErrorIs(err, lk.ErrMalformed)
+			s.Require().False(ok)
+		}
+
+		j, err := license.MarshalJSON()
+		s.Require().NoError(err)
+		var m map[string]interface{}
+		s.Require().NoError(json.Unmarshal(j, &m))
+		m["r"] = strings.Repeat("00", 32)
+		j, err = json.Marshal(m)
+		s.Require().NoError(err)
+		_, err = lk.ParseLicense(string(j))
+		s.Require().ErrorIs(err, lk.ErrMalformed)
+	})
+
+	s.Run("should reject out of range private keys", func() {
+		for _, d := range []*big.Int{nil, big.NewInt(0), new(big.Int).Lsh(big.NewInt(1), 300)} {
+			var buf bytes.Buffer
+			err := gob.NewEncoder(&buf).Encode(struct {
+				Pub []byte
+				D   *big.Int
+			}{privateKey.GetPublicKey().ToBytes(), d})
+			s.Require().NoError(err)
+
+			_, err = lk.PrivateKeyFromBytes(buf.Bytes())
+			s.Require().ErrorIs(err, lk.ErrMalformed)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package lk
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/sm3"
+)
+
+var (
+	// ErrInvalidPath is returned when a derivation path is empty or has an
+	// invalid component.
+	ErrInvalidPath = errors.New("lk: invalid derivation path")
+	// ErrInvalidSeed is returned when a seed is too short.
+	ErrInvalidSeed = errors.New("lk: invalid seed")
+)
+
+const (
+	// SeedSize is the size of the seeds created by NewSeed.
+	SeedSize = 32
+	// minSeedSize is the minimum size of a seed.
+	minSeedSize = 16
+)
+
+// NewSeed returns a random seed to derive keys from. Keep it as secret as a
+// private key, all the derived keys can be recreated from it.
+func NewSeed() ([]byte, error) {
+	seed := make([]byte, SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	return seed, nil
+}
+
+// hkdfExpand returns the first block of HKDF-Expand with HMAC-SM3.
+func hkdfExpand(prk []byte, info string) []byte {
+	m := hmac.New(sm3.New, prk)
+	m.Write([]byte(info))
+	m.Write([]byte{1})
+	return m.Sum(nil)
+}
+
+// DeriveKey derives a private key from the seed and a path of names
+// separated by slashes, such as "editor/pro/2026". The same seed and path
+// always give the same key.
+//
+// The derivation is hierarchical, in the manner of HKDF on SM3: the seed is
+// extracted into a pseudorandom key, each component of the path is expanded
+// from the key of its parent and the scalar is expanded from the key of the
+// last component. A key reveals nothing about the seed, its parent or its
+// siblings.
+func DeriveKey(seed []byte, path string) (*PrivateKey, error) {
+	if len(seed) < minSeedSize {
+		return nil, fmt.Errorf("%w: %d bytes, at least %d are needed", ErrInvalidSeed, len(seed), minSeedSize)
+	}
+	components := strings.Split(path, "/")
+	for _, c := range components {
+		if !validName.MatchString(c) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidPath, path)
+		}
+	}
+
+	// HKDF-Extract
+	m := hmac.New(sm3.New, []byte("gmsm-lk derive v1"))
+	m.Write(seed)
+	prk := m.Sum(nil)
+
+	for _, c := range components {
+		prk = hkdfExpand(prk, "child "+c)
+	}
+
+	nMinus1 := new(big.Int).Sub(sm2.P256().Params().N, big.NewInt(1))
+	for i := 0; ; i++ {
+		d := new(big.Int).SetBytes(hkdfExpand(prk, fmt.Sprintf("sm2 key %d", i)))
+		if d.Sign() == 0 || d.Cmp(nMinus1) >= 0 {
+			continue
+		}
+		key, err := sm2.NewPrivateKeyFromInt(d)
+		if err != nil {
+			return nil, err
+		}
+		return &PrivateKey{key: key}, nil
+	}
+}
@@ -0,0 +1,60 @@
+package lk_test
+
+import (
+	lk "github.com/phox/gmsm-lk"
+)
+
+func (s *Suite) TestDeriveKey() {
+	seed, err := lk.NewSeed()
+	s.Require().NoError(err)
+	s.Require().Len(seed, lk.SeedSize)
+
+	fingerprint := func(seed []byte, path string) string {
+		k, err := lk.DeriveKey(seed, path)
+		s.Require().NoError(err)
+		return k.GetPublicKey().Fingerprint()
+	}
+
+	s.Run("should derive the same key from the same path", func() {
+		s.Require().Equal(fingerprint(seed, "editor/pro/2026"), fingerprint(seed, "editor/pro/2026"))
+
+		k, err := lk.DeriveKey(seed, "editor/pro/2026")
+		s.Require().NoError(err)
+		l, err := lk.NewLicense(k, []byte("data"))
+		s.Require().NoError(err)
+		s.Require().NoError(l.VerifyErr(k.GetPublicKey()))
+	})
+
+	s.Run("should derive different keys", func() {
+		other, err := lk.NewSeed()
+		s.Require().NoError(err)
+
+		seen := map[string]bool{}
+		for _, fp := range []string{
+			fingerprint(seed, "editor"),
+			fingerprint(seed, "editor/pro"),
+			fingerprint(seed, "editor/pro/2026"),
+			fingerprint(seed, "editor/pro/2027"),
+			fingerprint(seed, "editorpro/2026"),
+			fingerprint(seed, "pro/editor/2026"),
+			fingerprint(other, "editor/pro/2026"),
+		} {
+			s.Require().False(seen[fp])
+			seen[fp] = true
+		}
+	})
+
+	s.Run("should keep a known key", func() {
+		fixed := []byte("0123456789abcdef0123456789abcdef")
+		s.Require().Equal("c6714500486875d52943f680ffbf5124afefd858434266e9e30e22edf912cefd", fingerprint(fixed, "editor/pro/2026"))
+	})
+
+	s.Run("should reject invalid paths and seeds", func() {
+		for _, path := range []string{"", "/editor", "editor/", "editor//pro", "editor/../pro", "a b"} {
+			_, err := lk.DeriveKey(seed, path)
+			s.Require().ErrorIs(err, lk.ErrInvalidPath, path)
+		}
+		_, err := lk.DeriveKey(seed[:8], "editor")
+		s.Require().ErrorIs(err, lk.ErrInvalidSeed)
+	})
+}
@@ -0,0 +1,72 @@
+package lk
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"math/big"
+)
+
+// Mask splits the public key in n parts XORed with random masks, so that the
+// key can be embedded in a binary without being a greppable string. Use
+// PublicKeyFromMasked to get the key back.
+func (k *PublicKey) Mask(n int) (parts, masks [][]byte, err error) {
+	b := k.ToBytes()
+	if n < 1 || n > len(b) {
+		return nil, nil, errors.New("lk: invalid number of parts")
+	}
+
+	// random cut points, each part has at least one byte
+	cuts := []int{0}
+	for i := 1; i < n; i++ {
+		left := len(b) - cuts[i-1] - (n - i)
+		r, err := rand.Int(rand.Reader, big.NewInt(int64(left)))
+		if err != nil {
+			return nil, nil, err
+		}
+		cuts = append(cuts, cuts[i-1]+1+int(r.Int64()))
+	}
+	cuts = append(cuts, len(b))
+
+	for i := 0; i < n; i++ {
+		chunk := b[cuts[i]:cuts[i+1]]
+		mask := make([]byte, len(chunk))
+		if _, err := rand.Read(mask); err != nil {
+			return nil, nil, err
+		}
+		part := make([]byte, len(chunk))
+		for j := range chunk {
+			part[j] = chunk[j] ^ mask[j]
+		}
+		parts = append(parts, part)
+		masks = append(masks, mask)
+	}
+	return parts, masks, nil
+}
+
+// PublicKeyFromMasked returns the public key split by Mask. The key must
+// match the fingerprint, the error is a KeyError otherwise.
+func PublicKeyFromMasked(parts, masks [][]byte, fingerprint string) (*PublicKey, error) {
+	if len(parts) != len(masks) {
+		return nil, &DecodeError{Encoding: "masked key", Err: errors.New("parts and masks differ in number")}
+	}
+
+	var b bytes.Buffer
+	for i, part := range parts {
+		if len(part) != len(masks[i]) {
+			return nil, &DecodeError{Encoding: "masked key", Err: errors.New("part and mask differ in length")}
+		}
+		for j := range part {
+			b.WriteByte(part[j] ^ masks[i][j])
+		}
+	}
+
+	k, err := PublicKeyFromBytes(b.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if fp := k.Fingerprint(); fp != fingerprint {
+		return nil, &KeyError{KeyID: fp, Expected: fingerprint}
+	}
+	return k, nil
+}
@@ -0,0 +1,53 @@
+package lk_test
+
+import (
+	lk "github.com/phox/gmsm-lk"
+)
+
+func (s *Suite) TestMask() {
+	privateKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+	publicKey := privateKey.GetPublicKey()
+
+	s.Run("should unmask the key", func() {
+		parts, masks, err := publicKey.Mask(4)
+		s.Require().NoError(err)
+		s.Require().Len(parts, 4)
+
+		size := 0
+		for i, part := range parts {
+			s.Require().NotEmpty(part)
+			s.Require().Len(masks[i], len(part))
+			size += len(part)
+		}
+		s.Require().Len(publicKey.ToBytes(), size)
+
+		k, err := lk.PublicKeyFromMasked(parts, masks, publicKey.Fingerprint())
+		s.Require().NoError(err)
+		s.Require().Equal(publicKey.ToBytes(), k.ToBytes())
+	})
+
+	s.Run("should check the fingerprint", func() {
+		parts, masks, err := publicKey.Mask(1)
+		s.Require().NoError(err)
+		parts[0][1] ^= masks[0][1]
+
+		_, err = lk.PublicKeyFromMasked(parts, masks, publicKey.Fingerprint())
+		s.Require().Error(err)
+
+		other, err := lk.NewPrivateKey()
+		s.Require().NoError(err)
+		parts, masks, err = other.GetPublicKey().Mask(3)
+		s.Require().NoError(err)
+		_, err = lk.PublicKeyFromMasked(parts, masks, publicKey.Fingerprint())
+		s.Require().ErrorIs(err, lk.ErrUnknownKey)
+	})
+
+	s.Run("should reject invalid parts", func() {
+		_, _, err := publicKey.Mask(0)
+		s.Require().Error(err)
+
+		_, err = lk.PublicKeyFromMasked([][]byte{{1}}, nil, publicKey.Fingerprint())
+		s.Require().ErrorIs(err, lk.ErrMalformed)
+	})
+}
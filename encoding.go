@@ -0,0 +1,280 @@
+package lk
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Encoding is a representation of the licenses, keys, bundles and
+// signatures.
+type Encoding string
+
+// The encodings. B32, B64 and Hex encode the binary representation, PEM
+// wraps it in a block, Raw is the binary representation itself.
+const (
+	EncodingB32  Encoding = "b32"
+	EncodingB64  Encoding = "b64"
+	EncodingHex  Encoding = "hex"
+	EncodingPEM  Encoding = "pem"
+	EncodingJSON Encoding = "json"
+	EncodingRaw  Encoding = "raw"
+)
+
+// Encodings lists the supported encodings.
+var Encodings = []Encoding{EncodingB32, EncodingB64, EncodingHex, EncodingPEM, EncodingJSON, EncodingRaw}
+
+// The types of the PEM blocks.
+const (
+	PEMLicense    = "GMSM-LK LICENSE"
+	PEMPublicKey  = "GMSM-LK PUBLIC KEY"
+	PEMPrivateKey = "GMSM-LK PRIVATE KEY"
+	PEMBundle     = "GMSM-LK BUNDLE"
+	PEMSignature  = "GMSM-LK SIGNATURE"
+)
+
+// ErrUnsupportedEncoding is returned when an object has no representation in
+// the requested encoding.
+var ErrUnsupportedEncoding = errors.New("lk: unsupported encoding")
+
+// ParseEncoding returns the encoding of the given name.
+func ParseEncoding(name string) (Encoding, error) {
+	for _, e := range Encodings {
+		if string(e) == strings.ToLower(name) {
+			return e, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnsupportedEncoding, name)
+}
+
+// sniff returns the encodings the input may be in, most likely first.
+func sniff(b []byte) []Encoding {
+	t := strings.TrimSpace(string(b))
+	switch {
+	case strings.HasPrefix(t, "-----BEGIN "):
+		return []Encoding{EncodingPEM}
+	case strings.HasPrefix(t, "{"):
+		return []Encoding{EncodingJSON}
+	case !isText(b):
+		return []Encoding{EncodingRaw}
+	}
+	return []Encoding{EncodingHex, EncodingB32, EncodingB64}
+}
+
+func isText(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// compact removes the white spaces and line breaks of a text encoding.
+func compact(b []byte) string {
+	return strings.Join(strings.Fields(string(b)), "")
+}
+
+// decodeAs returns the binary representation of the input in the encoding.
+func decodeAs(b []byte, enc Encoding, pemType string) ([]byte, error) {
+	switch enc {
+	case EncodingB32:
+		return decodeB32(compact(b))
+	case EncodingB64:
+		return decodeB64(compact(b))
+	case EncodingHex:
+		return decodeHex(compact(b))
+	case EncodingPEM:
+		block, _ := pem.Decode(b)
+		if block == nil {
+			return nil, &DecodeError{Encoding: "pem", Err: errors.New("no pem block")}
+		}
+		if block.Type != pemType {
+			return nil, &DecodeError{Encoding: "pem", Err: fmt.Errorf("unexpected block type %q", block.Type)}
+		}
+		return block.Bytes, nil
+	case EncodingRaw:
+		return b, nil
+	}
+	return nil, ErrUnsupportedEncoding
+}
+
+// decodeAny decodes an input in any of the encodings. fromJSON may be nil if
+// the object has no json representation.
+func decodeAny(b []byte, pemType string, fromBytes, fromJSON func([]byte) error) (Encoding, error) {
+	var textErr, parseErr error
+	for _, enc := range sniff(b) {
+		var err error
+		if enc == EncodingJSON {
+			if fromJSON == nil {
+				return "", ErrUnsupportedEncoding
+			}
+			if err = fromJSON([]byte(strings.TrimSpace(string(b)))); err != nil && !errors.Is(err, ErrMalformed) {
+				err = &DecodeError{Encoding: "json", Err: err}
+			}
+		} else if raw, derr := decodeAs(b, enc, pemType); derr != nil {
+			if textErr == nil {
+				textErr = derr
+			}
+			continue
+		} else {
+			err = fromBytes(raw)
+		}
+
+		if err == nil {
+			return enc, nil
+		}
+		if parseErr == nil {
+			parseErr = err
+		}
+	}
+	if parseErr != nil {
+		return "", parseErr
+	}
+	return "", textErr
+}
+
+// encodeAny encodes the binary representation of an object. toJSON may be
+// nil if the object has no json representation.
+func encodeAny(enc Encoding, pemType string, toBytes, toJSON func() ([]byte, error)) ([]byte, error) {
+	if enc == EncodingJSON {
+		if toJSON == nil {
+			return nil, fmt.Errorf("%w: %q", ErrUnsupportedEncoding, enc)
+		}
+		return toJSON()
+	}
+
+	b, err := toBytes()
+	if err != nil {
+		return nil, err
+	}
+	switch enc {
+	case EncodingB32:
+		return []byte(base32.StdEncoding.EncodeToString(b)), nil
+	case EncodingB64:
+		return []byte(base64.StdEncoding.EncodeToString(b)), nil
+	case EncodingHex:
+		return []byte(hex.EncodeToString(b)), nil
+	case EncodingPEM:
+		return pem.EncodeToMemory(&pem.Block{Type: pemType, Bytes: b}), nil
+	case EncodingRaw:
+		return b, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedEncoding, enc)
+}
+
+// Encode returns the license in the encoding.
+func (l *License) Encode(enc Encoding) ([]byte, error) {
+	return encodeAny(enc, PEMLicense, l.ToBytes, func() ([]byte, error) { return json.Marshal(l) })
+}
+
+// DecodeLicense returns a license in any encoding and the encoding that was
+// detected. Surrounding white spaces and line breaks are ignored. The license
+// is decoded by a StrictDecoder with no maximum size, use a StrictDecoder to
+// limit the size of untrusted inputs.
+func DecodeLicense(b []byte) (*License, Encoding, error) {
+	return (&StrictDecoder{MaxSize: -1}).DecodeLicense(b)
+}
+
+// ParseLicense returns a license in any encoding. Surrounding white spaces
+// and line breaks are ignored.
+func ParseLicense(str string) (*License, error) {
+	l, _, err := DecodeLicense([]byte(str))
+	return l, err
+}
+
+// Encode returns the public key in the encoding.
+func (k *PublicKey) Encode(enc Encoding) ([]byte, error) {
+	return encodeAny(enc, PEMPublicKey, func() ([]byte, error) {
+		return k.ToBytes(), nil
+	}, func() ([]byte, error) {
+		return json.Marshal(k)
+	})
+}
+
+// ParsePublicKey returns a public key in any encoding.
+func ParsePublicKey(str string) (*PublicKey, error) {
+	var k *PublicKey
+	_, err := decodeAny([]byte(str), PEMPublicKey, func(raw []byte) error {
+		var err error
+		k, err = PublicKeyFromBytes(raw)
+		return err
+	}, func(j []byte) error {
+		k = &PublicKey{}
+		return json.Unmarshal(j, k)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Encode returns the private key in the encoding.
+func (k *PrivateKey) Encode(enc Encoding) ([]byte, error) {
+	return encodeAny(enc, PEMPrivateKey, k.ToBytes, func() ([]byte, error) { return json.Marshal(k) })
+}
+
+// ParsePrivateKey returns a private key in any encoding.
+func ParsePrivateKey(str string) (*PrivateKey, error) {
+	var k *PrivateKey
+	_, err := decodeAny([]byte(str), PEMPrivateKey, func(raw []byte) error {
+		var err error
+		k, err = PrivateKeyFromBytes(raw)
+		return err
+	}, func(j []byte) error {
+		k = &PrivateKey{}
+		return json.Unmarshal(j, k)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Encode returns the bundle in the encoding, json is not supported.
+func (b *Bundle) Encode(enc Encoding) ([]byte, error) {
+	return encodeAny(enc, PEMBundle, b.ToBytes, nil)
+}
+
+// ParseBundle returns a bundle in any encoding but json.
+func ParseBundle(str string) (*Bundle, error) {
+	var b *Bundle
+	_, err := decodeAny([]byte(str), PEMBundle, func(raw []byte) error {
+		var err error
+		b, err = BundleFromBytes(raw)
+		return err
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Encode returns the signature in the encoding, json is not supported.
+func (sig *Signature) Encode(enc Encoding) ([]byte, error) {
+	return encodeAny(enc, PEMSignature, sig.ToBytes, nil)
+}
+
+// ParseSignature returns a detached signature in any encoding but json.
+func ParseSignature(str string) (*Signature, error) {
+	var sig *Signature
+	_, err := decodeAny([]byte(str), PEMSignature, func(raw []byte) error {
+		var err error
+		sig, err = SignatureFromBytes(raw)
+		return err
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
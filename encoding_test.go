@@ -0,0 +1,100 @@
+package lk_test
+
+import (
+	"strings"
+
+	lk "github.com/phox/gmsm-lk"
+)
+
+// wrap breaks a string in lines of n characters.
+func wrap(s string, n int) string {
+	var b strings.Builder
+	for len(s) > n {
+		b.WriteString(s[:n] + "\r\n")
+		s = s[n:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+func (s *Suite) TestEncoding() {
+	privateKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+	publicKey := privateKey.GetPublicKey()
+	license, err := lk.NewLicense(privateKey, s.RandomBytes(100))
+	s.Require().NoError(err)
+
+	s.Run("should parse the encoding names", func() {
+		for _, enc := range lk.Encodings {
+			e, err := lk.ParseEncoding(strings.ToUpper(string(enc)))
+			s.Require().NoError(err)
+			s.Require().Equal(enc, e)
+		}
+		_, err := lk.ParseEncoding("b58")
+		s.Require().ErrorIs(err, lk.ErrUnsupportedEncoding)
+	})
+
+	s.Run("should detect the license encoding", func() {
+		for _, enc := range lk.Encodings {
+			b, err := license.Encode(enc)
+			s.Require().NoError(err)
+
+			l, detected, err := lk.DecodeLicense(b)
+			s.Require().NoError(err, enc)
+			s.Require().Equal(enc, detected)
+			s.Require().Equal(license.Data, l.Data)
+			s.Require().NoError(l.VerifyErr(publicKey))
+		}
+	})
+
+	s.Run("should tolerate white spaces and line wraps", func() {
+		for _, enc := range []lk.Encoding{lk.EncodingB32, lk.EncodingB64, lk.EncodingHex} {
+			b, err := license.Encode(enc)
+			s.Require().NoError(err)
+
+			l, err := lk.ParseLicense("\n  " + wrap(string(b), 64) + "\n")
+			s.Require().NoError(err, enc)
+			s.Require().Equal(license.Data, l.Data)
+		}
+	})
+
+	s.Run("should detect the key encodings", func() {
+		for _, enc := range lk.Encodings {
+			b, err := publicKey.Encode(enc)
+			s.Require().NoError(err)
+			k, err := lk.ParsePublicKey(string(b))
+			s.Require().NoError(err, enc)
+			s.Require().Equal(publicKey.ToBytes(), k.ToBytes())
+
+			b, err = privateKey.Encode(enc)
+			s.Require().NoError(err)
+			pk, err := lk.ParsePrivateKey(string(b))
+			s.Require().NoError(err, enc)
+			s.Require().Equal(publicKey.ToBytes(), pk.GetPublicKey().ToBytes())
+		}
+	})
+
+	s.Run("should check the pem block type", func() {
+		b, err := publicKey.Encode(lk.EncodingPEM)
+		s.Require().NoError(err)
+		_, err = lk.ParseLicense(string(b))
+		s.Require().ErrorIs(err, lk.ErrMalformed)
+	})
+
+	s.Run("should not encode bundles in json", func() {
+		bl, err := lk.NewBundle(privateKey, []lk.BundleEntry{{Product: "a", Data: []byte("a")}})
+		s.Require().NoError(err)
+		_, err = bl.Encode(lk.EncodingJSON)
+		s.Require().ErrorIs(err, lk.ErrUnsupportedEncoding)
+
+		b, err := bl.Encode(lk.EncodingPEM)
+		s.Require().NoError(err)
+		_, err = lk.ParseBundle(string(b))
+		s.Require().NoError(err)
+	})
+
+	s.Run("should reject garbage", func() {
+		_, err := lk.ParseLicense("not a license")
+		s.Require().ErrorIs(err, lk.ErrMalformed)
+	})
+}
@@ -0,0 +1,96 @@
+package lk
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The errors returned by the library can be tested with errors.Is against
+// these sentinels, whatever the decode or verify path that produced them.
+var (
+	// ErrMalformed is returned when a license, a key or a token can't be
+	// decoded.
+	ErrMalformed = errors.New("lk: malformed input")
+
+	// ErrBadSignature is returned when a signature doesn't match the public
+	// key.
+	ErrBadSignature = errors.New("lk: bad signature")
+
+	// ErrInvalidSignature is the former name of ErrBadSignature.
+	//
+	// Deprecated: use ErrBadSignature.
+	ErrInvalidSignature = ErrBadSignature
+
+	// ErrExpired is returned when a license is used after its expiration
+	// date.
+	ErrExpired = errors.New("lk: license expired")
+
+	// ErrNotYetValid is returned when a license is used before its not
+	// before date.
+	ErrNotYetValid = errors.New("lk: license not yet valid")
+
+	// ErrRevoked is returned when the serial of a license was revoked.
+	ErrRevoked = errors.New("lk: license revoked")
+
+	// ErrWrongMachine is returned when a license is used on another machine
+	// than the one it was issued for.
+	ErrWrongMachine = errors.New("lk: license issued for another machine")
+
+	// ErrUnknownKey is returned when a document was signed by another key.
+	ErrUnknownKey = errors.New("lk: unknown key")
+)
+
+// DecodeError is returned when an input can't be decoded. It matches
+// ErrMalformed and unwraps to the error of the underlying decoder.
+type DecodeError struct {
+	Encoding string
+	Err      error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("lk: malformed %s: %v", e.Encoding, e.Err)
+}
+
+// Unwrap returns the error of the underlying decoder.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrMalformed.
+func (e *DecodeError) Is(target error) bool {
+	return target == ErrMalformed
+}
+
+// ValidityError is returned when a license is used outside of its validity
+// period. It unwraps to ErrExpired or ErrNotYetValid.
+type ValidityError struct {
+	Err  error
+	Date time.Time
+	Now  time.Time
+}
+
+func (e *ValidityError) Error() string {
+	return fmt.Sprintf("%v: %s (now %s)", e.Err, e.Date.Format(time.RFC3339), e.Now.Format(time.RFC3339))
+}
+
+// Unwrap returns ErrExpired or ErrNotYetValid.
+func (e *ValidityError) Unwrap() error {
+	return e.Err
+}
+
+// KeyError is returned when a license was signed by another key than the one
+// used to verify it. It unwraps to ErrUnknownKey.
+type KeyError struct {
+	KeyID    string
+	Expected string
+}
+
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("%v: %s (expected %s)", ErrUnknownKey, e.KeyID, e.Expected)
+}
+
+// Unwrap returns ErrUnknownKey.
+func (e *KeyError) Unwrap() error {
+	return ErrUnknownKey
+}
@@ -0,0 +1,90 @@
+package lk_test
+
+import (
+	"errors"
+	"time"
+
+	lk "github.com/phox/gmsm-lk"
+)
+
+func (s *Suite) TestErrors() {
+	privateKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+	wrongKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+	publicKey := privateKey.GetPublicKey()
+
+	s.Run("should match malformed inputs", func() {
+		_, err := lk.LicenseFromB32String("not base32!")
+		s.Require().ErrorIs(err, lk.ErrMalformed)
+
+		var de *lk.DecodeError
+		s.Require().True(errors.As(err, &de))
+		s.Require().Equal("base32", de.Encoding)
+
+		_, err = lk.LicenseFromBytes(s.RandomBytes(42))
+		s.Require().ErrorIs(err, lk.ErrMalformed)
+		_, err = lk.LicenseFromHexString(s.RandomB64String(42))
+		s.Require().ErrorIs(err, lk.ErrMalformed)
+		_, err = lk.PublicKeyFromBytes(s.RandomBytes(42))
+		s.Require().ErrorIs(err, lk.ErrMalformed)
+		s.Require().ErrorIs(err, lk.ErrInvalidPublicKey)
+		_, err = lk.PrivateKeyFromB64String(s.RandomB32String(42))
+		s.Require().ErrorIs(err, lk.ErrMalformed)
+		_, err = lk.ParseJWT("a.b", publicKey)
+		s.Require().ErrorIs(err, lk.ErrMalformed)
+		_, err = lk.ParseCWT(s.RandomBytes(10), publicKey)
+		s.Require().ErrorIs(err, lk.ErrMalformed)
+
+		l := &lk.License{Data: []byte("data")}
+		s.Require().ErrorIs(l.VerifyErr(publicKey), lk.ErrMalformed)
+	})
+
+	s.Run("should tell a bad signature from a wrong key", func() {
+		l, err := lk.NewLicense(privateKey, []byte("data"))
+		s.Require().NoError(err)
+		s.Require().NoError(l.VerifyErr(publicKey))
+
+		err = l.VerifyErr(wrongKey.GetPublicKey())
+		s.Require().ErrorIs(err, lk.ErrUnknownKey)
+		var ke *lk.KeyError
+		s.Require().True(errors.As(err, &ke))
+		s.Require().Equal(publicKey.Fingerprint(), ke.KeyID)
+
+		l.Data = []byte("forged")
+		s.Require().ErrorIs(l.VerifyErr(publicKey), lk.ErrBadSignature)
+		s.Require().ErrorIs(l.VerifyErr(publicKey), lk.ErrInvalidSignature)
+	})
+
+	s.Run("should check the claims", func() {
+		now := time.Now()
+		l, err := lk.NewClaimsLicense(privateKey, &lk.Claims{
+			NotBefore: now.Add(-time.Hour),
+			Expires:   now.Add(time.Hour),
+			Machine:   "machine-1",
+		})
+		s.Require().NoError(err)
+
+		c, err := l.VerifyClaims(publicKey, lk.VerifyOptions{Machine: "machine-1"})
+		s.Require().NoError(err)
+		s.Require().Equal("machine-1", c.Machine)
+
+		_, err = l.VerifyClaims(publicKey, lk.VerifyOptions{Now: now.Add(2 * time.Hour)})
+		s.Require().ErrorIs(err, lk.ErrExpired)
+		var ve *lk.ValidityError
+		s.Require().True(errors.As(err, &ve))
+		s.Require().True(ve.Date.Equal(c.Expires))
+
+		_, err = l.VerifyClaims(publicKey, lk.VerifyOptions{Now: now.Add(-2 * time.Hour)})
+		s.Require().ErrorIs(err, lk.ErrNotYetValid)
+
+		_, err = l.VerifyClaims(publicKey, lk.VerifyOptions{Machine: "machine-2"})
+		s.Require().ErrorIs(err, lk.ErrWrongMachine)
+
+		_, err = l.VerifyClaims(publicKey, lk.VerifyOptions{Revoked: lk.NewRevocationList(c.Serial)})
+		s.Require().ErrorIs(err, lk.ErrRevoked)
+
+		_, err = l.VerifyClaims(wrongKey.GetPublicKey(), lk.VerifyOptions{})
+		s.Require().ErrorIs(err, lk.ErrUnknownKey)
+	})
+}
@@ -0,0 +1,587 @@
+package lk
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrInvalidExpr is returned when a policy expression can't be parsed or
+// evaluated.
+var ErrInvalidExpr = errors.New("lk: invalid expression")
+
+// The policy expressions are a small language over the claims:
+//
+//	product in ["editor", "suite"] && seats >= env.users
+//	vercmp(env.version, "2.0") >= 0 && !(region == "cn")
+//	expires > now + days(30)
+//
+// Values are numbers, strings, booleans, null, times, lists and maps. The
+// operators are || && ! == != < <= > >= in + - * / and the functions len,
+// contains, vercmp and days.
+
+type exprToken struct {
+	kind string // "num", "str", "ident" or the operator itself
+	text string
+	pos  int
+}
+
+func tokenizeExpr(src string) ([]exprToken, error) {
+	var toks []exprToken
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c):
+			j := i
+			for j < len(src) && (unicode.IsDigit(rune(src[j])) || src[j] == '.') {
+				j++
+			}
+			toks = append(toks, exprToken{"num", src[i:j], i})
+			i = j
+		case c == '"' || c == '\'':
+			j := i + 1
+			var sb strings.Builder
+			for ; j < len(src) && rune(src[j]) != c; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+				}
+				sb.WriteByte(src[j])
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("%w: unterminated string at %d", ErrInvalidExpr, i)
+			}
+			toks = append(toks, exprToken{"str", sb.String(), i})
+			i = j + 1
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(src) && (unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j])) ||
+				src[j] == '_' || src[j] == '.') {
+				j++
+			}
+			toks = append(toks, exprToken{"ident", src[i:j], i})
+			i = j
+		default:
+			op := ""
+			for _, o := range []string{"||", "&&", "==", "!=", "<=", ">=", "<", ">", "!",
+				"(", ")", "[", "]", ",", "+", "-", "*", "/"} {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("%w: unexpected %q at %d", ErrInvalidExpr, c, i)
+			}
+			toks = append(toks, exprToken{op, op, i})
+			i += len(op)
+		}
+	}
+	return toks, nil
+}
+
+// exprNode is a node of a parsed expression.
+type exprNode interface {
+	eval(vars map[string]interface{}) (interface{}, error)
+}
+
+type (
+	exprLit   struct{ v interface{} }
+	exprVar   struct{ name string }
+	exprList  struct{ items []exprNode }
+	exprUnary struct {
+		op string
+		x  exprNode
+	}
+	exprBinary struct {
+		op   string
+		x, y exprNode
+	}
+	exprCall struct {
+		fn   string
+		args []exprNode
+	}
+)
+
+// maxExprDepth caps the nesting of an expression so a hostile policy can't
+// exhaust the stack.
+const maxExprDepth = 64
+
+type exprParser struct {
+	toks  []exprToken
+	pos   int
+	depth int
+}
+
+// parseExpr parses an expression of the policy language.
+func parseExpr(src string) (exprNode, error) {
+	toks, err := tokenizeExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{toks: toks}
+	n, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.toks) {
+		return nil, p.errorf("unexpected %q", p.toks[p.pos].text)
+	}
+	return n, nil
+}
+
+var exprPrecedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3, "in": 3,
+	"+": 4, "-": 4,
+	"*": 5, "/": 5,
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	pos := -1
+	if p.pos < len(p.toks) {
+		pos = p.toks[p.pos].pos
+	}
+	return fmt.Errorf("%w: %s at %d", ErrInvalidExpr, fmt.Sprintf(format, args...), pos)
+}
+
+func (p *exprParser) peek() string {
+	if p.pos >= len(p.toks) {
+		return ""
+	}
+	t := p.toks[p.pos]
+	if t.kind == "ident" && t.text == "in" {
+		return "in"
+	}
+	return t.kind
+}
+
+func (p *exprParser) expect(kind string) error {
+	if p.peek() != kind {
+		return p.errorf("expected %q", kind)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *exprParser) parseBinary(minPrec int) (exprNode, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		prec, ok := exprPrecedence[op]
+		if !ok || prec <= minPrec {
+			return x, nil
+		}
+		p.pos++
+		y, err := p.parseBinary(prec)
+		if err != nil {
+			return nil, err
+		}
+		x = &exprBinary{op, x, y}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.depth >= maxExprDepth {
+		return nil, p.errorf("too deeply nested")
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+
+	switch p.peek() {
+	case "!", "-":
+		op := p.toks[p.pos].text
+		p.pos++
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &exprUnary{op, x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parseList(end string) ([]exprNode, error) {
+	var items []exprNode
+	for p.peek() != end {
+		n, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, n)
+		if p.peek() != "," {
+			break
+		}
+		p.pos++
+	}
+	return items, p.expect(end)
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	if p.pos >= len(p.toks) {
+		return nil, p.errorf("unexpected end")
+	}
+	t := p.toks[p.pos]
+	p.pos++
+
+	switch t.kind {
+	case "num":
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid number %q", ErrInvalidExpr, t.text)
+		}
+		return &exprLit{f}, nil
+	case "str":
+		return &exprLit{t.text}, nil
+	case "(":
+		n, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expect(")")
+	case "[":
+		items, err := p.parseList("]")
+		return &exprList{items}, err
+	case "ident":
+		switch t.text {
+		case "true":
+			return &exprLit{true}, nil
+		case "false":
+			return &exprLit{false}, nil
+		case "null":
+			return &exprLit{nil}, nil
+		}
+		if p.peek() == "(" {
+			p.pos++
+			args, err := p.parseList(")")
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := exprFuncs[t.text]; !ok {
+				return nil, fmt.Errorf("%w: unknown function %q", ErrInvalidExpr, t.text)
+			}
+			return &exprCall{t.text, args}, nil
+		}
+		return &exprVar{t.text}, nil
+	}
+	p.pos--
+	return nil, p.errorf("unexpected %q", t.text)
+}
+
+func (n *exprLit) eval(map[string]interface{}) (interface{}, error) {
+	return n.v, nil
+}
+
+// eval resolves a dotted name in the variables, a missing name is null.
+func (n *exprVar) eval(vars map[string]interface{}) (interface{}, error) {
+	var v interface{} = vars
+	for _, part := range strings.Split(n.name, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		v = m[part]
+	}
+	return normalizeExprValue(v), nil
+}
+
+func (n *exprList) eval(vars map[string]interface{}) (interface{}, error) {
+	res := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		v, err := item.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = v
+	}
+	return res, nil
+}
+
+func (n *exprUnary) eval(vars map[string]interface{}) (interface{}, error) {
+	x, err := n.x.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "!" {
+		b, ok := x.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%w: ! of %T", ErrInvalidExpr, x)
+		}
+		return !b, nil
+	}
+	f, ok := x.(float64)
+	if !ok {
+		return nil, fmt.Errorf("%w: - of %T", ErrInvalidExpr, x)
+	}
+	return -f, nil
+}
+
+func (n *exprBinary) eval(vars map[string]interface{}) (interface{}, error) {
+	x, err := n.x.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	// && and || are short-circuited
+	if n.op == "&&" || n.op == "||" {
+		bx, ok := x.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s of %T", ErrInvalidExpr, n.op, x)
+		}
+		if bx == (n.op == "||") {
+			return bx, nil
+		}
+		y, err := n.y.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		by, ok := y.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s of %T", ErrInvalidExpr, n.op, y)
+		}
+		return by, nil
+	}
+
+	y, err := n.y.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return exprEqual(x, y), nil
+	case "!=":
+		return !exprEqual(x, y), nil
+	case "in":
+		return exprContains(y, x)
+	case "<", "<=", ">", ">=":
+		c, err := exprCompare(x, y)
+		if err != nil {
+			return nil, err
+		}
+		switch n.op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	}
+	return exprArith(n.op, x, y)
+}
+
+func (n *exprCall) eval(vars map[string]interface{}) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, a := range n.args {
+		v, err := a.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return exprFuncs[n.fn](args)
+}
+
+// normalizeExprValue converts the numeric types to float64.
+func normalizeExprValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case []string:
+		res := make([]interface{}, len(v))
+		for i, s := range v {
+			res[i] = s
+		}
+		return res
+	case map[string]int64:
+		res := make(map[string]interface{}, len(v))
+		for k, n := range v {
+			res[k] = float64(n)
+		}
+		return res
+	}
+	return v
+}
+
+func exprEqual(x, y interface{}) bool {
+	if tx, ok := x.(time.Time); ok {
+		ty, ok := y.(time.Time)
+		return ok && tx.Equal(ty)
+	}
+	// Only the scalars compare: == panics on uncomparable values.
+	if !isExprScalar(x) || !isExprScalar(y) {
+		return false
+	}
+	return x == y
+}
+
+func isExprScalar(v interface{}) bool {
+	switch v.(type) {
+	case nil, bool, float64, string:
+		return true
+	}
+	return false
+}
+
+func exprContains(list, x interface{}) (interface{}, error) {
+	switch l := list.(type) {
+	case nil:
+		return false, nil
+	case []interface{}:
+		for _, item := range l {
+			if exprEqual(item, x) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case map[string]interface{}:
+		s, ok := x.(string)
+		_, found := l[s]
+		return ok && found, nil
+	case string:
+		s, ok := x.(string)
+		return ok && strings.Contains(l, s), nil
+	}
+	return nil, fmt.Errorf("%w: in %T", ErrInvalidExpr, list)
+}
+
+func exprCompare(x, y interface{}) (int, error) {
+	switch x := x.(type) {
+	case float64:
+		if y, ok := y.(float64); ok {
+			switch {
+			case x < y:
+				return -1, nil
+			case x > y:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	case string:
+		if y, ok := y.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+	case time.Time:
+		if y, ok := y.(time.Time); ok {
+			return x.Compare(y), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: can't compare %T and %T", ErrInvalidExpr, x, y)
+}
+
+func exprArith(op string, x, y interface{}) (interface{}, error) {
+	if t, ok := x.(time.Time); ok {
+		if d, ok := y.(time.Duration); ok && (op == "+" || op == "-") {
+			if op == "-" {
+				d = -d
+			}
+			return t.Add(d), nil
+		}
+	}
+	if s, ok := x.(string); ok && op == "+" {
+		if s2, ok := y.(string); ok {
+			return s + s2, nil
+		}
+	}
+
+	fx, ok1 := x.(float64)
+	fy, ok2 := y.(float64)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("%w: %T %s %T", ErrInvalidExpr, x, op, y)
+	}
+	switch op {
+	case "+":
+		return fx + fy, nil
+	case "-":
+		return fx - fy, nil
+	case "*":
+		return fx * fy, nil
+	}
+	if fy == 0 {
+		return nil, fmt.Errorf("%w: division by zero", ErrInvalidExpr)
+	}
+	return fx / fy, nil
+}
+
+// exprFuncs are the functions of the policy language.
+var exprFuncs = map[string]func(args []interface{}) (interface{}, error){
+	"len": func(args []interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%w: len takes 1 argument", ErrInvalidExpr)
+		}
+		switch v := args[0].(type) {
+		case nil:
+			return float64(0), nil
+		case string:
+			return float64(len(v)), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		}
+		return nil, fmt.Errorf("%w: len of %T", ErrInvalidExpr, args[0])
+	},
+	"contains": func(args []interface{}) (interface{}, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%w: contains takes 2 arguments", ErrInvalidExpr)
+		}
+		return exprContains(args[0], args[1])
+	},
+	"vercmp": func(args []interface{}) (interface{}, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%w: vercmp takes 2 arguments", ErrInvalidExpr)
+		}
+		a, ok1 := args[0].(string)
+		b, ok2 := args[1].(string)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("%w: vercmp of %T and %T", ErrInvalidExpr, args[0], args[1])
+		}
+		return float64(compareVersions(a, b)), nil
+	},
+	"days": func(args []interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%w: days takes 1 argument", ErrInvalidExpr)
+		}
+		f, ok := args[0].(float64)
+		if !ok || math.Abs(f) > 1e6 {
+			return nil, fmt.Errorf("%w: days of %v", ErrInvalidExpr, args[0])
+		}
+		return time.Duration(f * float64(24*time.Hour)), nil
+	},
+}
+
+// compareVersions compares dotted versions numerically, "1.10" > "1.9". A
+// leading "v" is ignored and missing components are zeros.
+func compareVersions(a, b string) int {
+	pa := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	pb := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var na, nb int
+		if i < len(pa) {
+			na, _ = strconv.Atoi(pa[i])
+		}
+		if i < len(pb) {
+			nb, _ = strconv.Atoi(pb[i])
+		}
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
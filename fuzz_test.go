@@ -0,0 +1,156 @@
+package lk_test
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+
+	lk "github.com/phox/gmsm-lk"
+)
+
+// fuzzSeeds returns a valid license, bundle, signature and keys to seed the
+// fuzz targets.
+func fuzzSeeds(f *testing.F) (*lk.PrivateKey, [][]byte) {
+	k, err := lk.NewPrivateKey()
+	if err != nil {
+		f.Fatal(err)
+	}
+	l, err := lk.NewLicense(k, []byte(`{"email":"user@example.com"}`))
+	if err != nil {
+		f.Fatal(err)
+	}
+	bl, err := lk.NewBundle(k, []lk.BundleEntry{{Product: "p", Data: []byte("data")}})
+	if err != nil {
+		f.Fatal(err)
+	}
+	sig := &lk.Signature{R: l.R, S: l.S, KeyID: l.KeyID}
+
+	var seeds [][]byte
+	for _, fn := range []func() ([]byte, error){l.ToBytes, bl.ToBytes, sig.ToBytes, k.ToBytes} {
+		b, err := fn()
+		if err != nil {
+			f.Fatal(err)
+		}
+		seeds = append(seeds, b)
+	}
+	return k, append(seeds, k.GetPublicKey().ToBytes())
+}
+
+func fuzzBytes(f *testing.F, decode func(k *lk.PrivateKey, b []byte)) {
+	k, seeds := fuzzSeeds(f)
+	for _, b := range seeds {
+		f.Add(b)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		decode(k, b)
+	})
+}
+
+func fuzzB32(f *testing.F, decode func(k *lk.PrivateKey, str string)) {
+	k, seeds := fuzzSeeds(f)
+	for _, b := range seeds {
+		f.Add(base32.StdEncoding.EncodeToString(b))
+	}
+	f.Fuzz(func(t *testing.T, str string) {
+		decode(k, str)
+	})
+}
+
+func verifyLicense(k *lk.PrivateKey, l *lk.License, err error) {
+	if err == nil {
+		_ = l.VerifyErr(k.GetPublicKey())
+		_, _ = l.Claims()
+	}
+}
+
+func FuzzLicenseFromBytes(f *testing.F) {
+	fuzzBytes(f, func(k *lk.PrivateKey, b []byte) {
+		l, err := lk.LicenseFromBytes(b)
+		verifyLicense(k, l, err)
+	})
+}
+
+func FuzzLicenseFromB32String(f *testing.F) {
+	fuzzB32(f, func(k *lk.PrivateKey, str string) {
+		l, err := lk.LicenseFromB32String(str)
+		verifyLicense(k, l, err)
+	})
+}
+
+func FuzzStrictLicenseFromBytes(f *testing.F) {
+	fuzzBytes(f, func(k *lk.PrivateKey, b []byte) {
+		l, err := (&lk.StrictDecoder{}).LicenseFromBytes(b)
+		verifyLicense(k, l, err)
+	})
+}
+
+func FuzzStrictLicenseFromB32String(f *testing.F) {
+	fuzzB32(f, func(k *lk.PrivateKey, str string) {
+		l, err := (&lk.StrictDecoder{}).LicenseFromB32String(str)
+		verifyLicense(k, l, err)
+	})
+}
+
+func FuzzPrivateKeyFromBytes(f *testing.F) {
+	fuzzBytes(f, func(_ *lk.PrivateKey, b []byte) {
+		if k, err := lk.PrivateKeyFromBytes(b); err == nil {
+			_ = k.GetPublicKey().Fingerprint()
+		}
+	})
+}
+
+func FuzzPrivateKeyFromB32String(f *testing.F) {
+	fuzzB32(f, func(_ *lk.PrivateKey, str string) {
+		if k, err := lk.PrivateKeyFromB32String(str); err == nil {
+			_ = k.GetPublicKey().Fingerprint()
+		}
+	})
+}
+
+func FuzzPublicKeyFromBytes(f *testing.F) {
+	fuzzBytes(f, func(_ *lk.PrivateKey, b []byte) {
+		if k, err := lk.PublicKeyFromBytes(b); err == nil {
+			_, _ = k.Prepare()
+		}
+	})
+}
+
+func FuzzPublicKeyFromB32String(f *testing.F) {
+	fuzzB32(f, func(_ *lk.PrivateKey, str string) {
+		if k, err := lk.PublicKeyFromB32String(str); err == nil {
+			_, _ = k.Prepare()
+		}
+	})
+}
+
+func FuzzBundleFromBytes(f *testing.F) {
+	fuzzBytes(f, func(k *lk.PrivateKey, b []byte) {
+		if bl, err := lk.BundleFromBytes(b); err == nil {
+			_, _ = bl.VerifyProduct(k.GetPublicKey(), "p")
+		}
+	})
+}
+
+func FuzzBundleFromB32String(f *testing.F) {
+	fuzzB32(f, func(k *lk.PrivateKey, str string) {
+		if bl, err := lk.BundleFromB32String(str); err == nil {
+			_, _ = bl.VerifyProduct(k.GetPublicKey(), "p")
+		}
+	})
+}
+
+func FuzzSignatureFromBytes(f *testing.F) {
+	fuzzBytes(f, func(k *lk.PrivateKey, b []byte) {
+		if sig, err := lk.SignatureFromBytes(b); err == nil {
+			_, _ = sig.VerifyReader(k.GetPublicKey(), strings.NewReader("data"))
+		}
+	})
+}
+
+func FuzzSignatureFromB32String(f *testing.F) {
+	fuzzB32(f, func(k *lk.PrivateKey, str string) {
+		if sig, err := lk.SignatureFromB32String(str); err == nil {
+			_, _ = sig.VerifyReader(k.GetPublicKey(), strings.NewReader("data"))
+		}
+	})
+}
@@ -3,6 +3,8 @@ module github.com/phox/gmsm-lk
 go 1.21
 
 require (
+	// nonce.go relies on how sm2.Sign reads its nonces: upgrade only when
+	// the known answer tests of nonce_test.go pass.
 	github.com/emmansun/gmsm v0.29.5
 	gopkg.in/alecthomas/kingpin.v2 v2.2.6
 )
@@ -17,6 +19,6 @@ require (
 	github.com/alecthomas/units v0.0.0-20211218093645-b94a6e3cc137 // indirect
 	github.com/stretchr/testify v1.11.1
 	golang.org/x/crypto v0.31.0 // indirect
-	golang.org/x/sys v0.28.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	golang.org/x/sys v0.28.0
+	gopkg.in/yaml.v3 v3.0.1
 )
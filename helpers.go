@@ -50,11 +50,38 @@ func fromBytes(obj interface{}, b []byte) error {
 	buffBin := bytes.NewBuffer(b)
 	decoder := gob.NewDecoder(buffBin)
 
-	return decoder.Decode(obj)
+	if err := decoder.Decode(obj); err != nil {
+		return &DecodeError{Encoding: "gob", Err: err}
+	}
+	return nil
 }
 
-func fromB64String(obj interface{}, s string) error {
+func decodeB64(s string) ([]byte, error) {
 	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, &DecodeError{Encoding: "base64", Err: err}
+	}
+	return b, nil
+}
+
+func decodeB32(s string) ([]byte, error) {
+	b, err := base32.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, &DecodeError{Encoding: "base32", Err: err}
+	}
+	return b, nil
+}
+
+func decodeHex(s string) ([]byte, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, &DecodeError{Encoding: "hex", Err: err}
+	}
+	return b, nil
+}
+
+func fromB64String(obj interface{}, s string) error {
+	b, err := decodeB64(s)
 	if err != nil {
 		return err
 	}
@@ -63,7 +90,7 @@ func fromB64String(obj interface{}, s string) error {
 }
 
 func fromB32String(obj interface{}, s string) error {
-	b, err := base32.StdEncoding.DecodeString(s)
+	b, err := decodeB32(s)
 	if err != nil {
 		return err
 	}
@@ -72,7 +99,7 @@ func fromB32String(obj interface{}, s string) error {
 }
 
 func fromHexString(obj interface{}, s string) error {
-	b, err := hex.DecodeString(s)
+	b, err := decodeHex(s)
 	if err != nil {
 		return err
 	}
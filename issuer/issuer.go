@@ -0,0 +1,453 @@
+// Package issuer provides a REST service issuing, fetching, renewing and
+// revoking licenses. The licenses are kept in a lk.Registry and the clients
+// authenticate with bearer tokens limited to some actions, products and
+// validity period:
+//
+//	srv, err := issuer.New(issuer.Config{Key: key, Registry: registry, Tokens: tokens})
+//	http.ListenAndServe(":8080", srv)
+//
+// The API is described by the OpenAPI document served at /openapi.json.
+package issuer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/phox/gmsm-lk"
+)
+
+// maxBodySize is the maximum size of a request body.
+const maxBodySize = 64 << 10
+
+// Config is the configuration of a Server.
+type Config struct {
+	// Key signs the licenses.
+	Key lk.Signer
+	// Registry stores the issued licenses.
+	Registry *lk.Registry
+	// Tokens are the accepted bearer tokens.
+	Tokens []Token
+	// Ledger records the issued licenses if not nil.
+	Ledger *lk.Ledger
+	// RateLimit is the number of requests per second allowed for each
+	// token, unlimited if not positive.
+	RateLimit float64
+	// Burst is the number of requests a token can send at once, the rate
+	// limit rounded up if not positive.
+	Burst int
+	// Clock returns the current time, time.Now if nil.
+	Clock func() time.Time
+}
+
+// Server is the http.Handler of the service.
+type Server struct {
+	cfg         Config
+	credentials []*credential
+}
+
+// New returns the server of the configuration.
+func New(cfg Config) (*Server, error) {
+	if cfg.Key == nil || cfg.Registry == nil {
+		return nil, errors.New("issuer: a key and a registry are required")
+	}
+	if cfg.Burst <= 0 {
+		cfg.Burst = int(math.Max(1, math.Ceil(cfg.RateLimit)))
+	}
+
+	s := &Server{cfg: cfg}
+	names := map[string]bool{}
+	for _, t := range cfg.Tokens {
+		if t.Name == "" || t.Token == "" {
+			return nil, errors.New("issuer: a token must have a name and a value")
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("issuer: duplicate token name %q", t.Name)
+		}
+		names[t.Name] = true
+		s.credentials = append(s.credentials, newCredential(t))
+	}
+	return s, nil
+}
+
+func (s *Server) now() time.Time {
+	if s.cfg.Clock != nil {
+		return s.cfg.Clock().UTC().Truncate(time.Second)
+	}
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// httpError is an error with its status code.
+type httpError struct {
+	status int
+	msg    string
+}
+
+func (e *httpError) Error() string {
+	return e.msg
+}
+
+func errorf(status int, format string, args ...interface{}) error {
+	return &httpError{status: status, msg: fmt.Sprintf(format, args...)}
+}
+
+// statusOf maps the errors of the library to status codes.
+func statusOf(err error) int {
+	var he *httpError
+	switch {
+	case errors.As(err, &he):
+		return he.status
+	case errors.Is(err, lk.ErrLicenseNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, lk.ErrSerialExists), errors.Is(err, lk.ErrRevoked):
+		return http.StatusConflict
+	case errors.Is(err, lk.ErrInvalidClaims), errors.Is(err, lk.ErrInvalidName), errors.Is(err, lk.ErrMalformed):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := statusOf(err)
+	msg := err.Error()
+	if status == http.StatusInternalServerError {
+		msg = http.StatusText(status)
+	}
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// readJSON decodes the request body, an empty body is accepted if empty is
+// set.
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}, empty bool) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.Is(err, io.EOF) && empty:
+			return nil
+		case errors.As(err, &tooLarge):
+			return errorf(http.StatusRequestEntityTooLarge, "request body too large")
+		}
+		return errorf(http.StatusBadRequest, "invalid request body: %v", err)
+	}
+	return nil
+}
+
+// authenticate returns the credential of the bearer token of the request.
+func (s *Server) authenticate(r *http.Request) (*credential, error) {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+		return nil, errorf(http.StatusUnauthorized, "missing bearer token")
+	}
+	digest := newCredential(Token{Token: strings.TrimSpace(auth[7:])}).digest
+
+	var found *credential
+	for _, c := range s.credentials {
+		if c.match(digest) {
+			found = c
+		}
+	}
+	if found == nil {
+		return nil, errorf(http.StatusUnauthorized, "invalid bearer token")
+	}
+	return found, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	if path == "/openapi.json" {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, openAPI)
+		return
+	}
+	if path != "/v1/revocations" && path != "/v1/licenses" && !strings.HasPrefix(path, "/v1/licenses/") {
+		writeError(w, errorf(http.StatusNotFound, "not found"))
+		return
+	}
+
+	c, err := s.authenticate(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="lk-issuer"`)
+		writeError(w, err)
+		return
+	}
+	if s.cfg.RateLimit > 0 {
+		if ok, wait := c.bucket.take(time.Now(), s.cfg.RateLimit, s.cfg.Burst); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeError(w, errorf(http.StatusTooManyRequests, "rate limit exceeded"))
+			return
+		}
+	}
+
+	switch parts := strings.Split(strings.TrimPrefix(path, "/v1/"), "/"); {
+	case len(parts) == 1 && parts[0] == "revocations":
+		if allowMethod(w, r, http.MethodGet) {
+			s.handle(w, c, ActionRead, func() (int, interface{}, error) { return s.revocations(c) })
+		}
+	case len(parts) == 1:
+		if allowMethod(w, r, http.MethodPost) {
+			s.handle(w, c, ActionIssue, func() (int, interface{}, error) { return s.issue(c, w, r) })
+		}
+	case len(parts) == 2:
+		if allowMethod(w, r, http.MethodGet) {
+			s.handle(w, c, ActionRead, func() (int, interface{}, error) { return s.fetch(c, parts[1]) })
+		}
+	case len(parts) == 3 && parts[2] == "renew":
+		if allowMethod(w, r, http.MethodPost) {
+			s.handle(w, c, ActionRenew, func() (int, interface{}, error) { return s.renew(c, parts[1], w, r) })
+		}
+	case len(parts) == 3 && parts[2] == "revoke":
+		if allowMethod(w, r, http.MethodPost) {
+			s.handle(w, c, ActionRevoke, func() (int, interface{}, error) { return s.revoke(c, parts[1], w, r) })
+		}
+	default:
+		writeError(w, errorf(http.StatusNotFound, "not found"))
+	}
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, errorf(http.StatusMethodNotAllowed, "method %s not allowed", r.Method))
+	return false
+}
+
+// handle checks that the token allows the action and writes the result of
+// fn.
+func (s *Server) handle(w http.ResponseWriter, c *credential, action string, fn func() (int, interface{}, error)) {
+	if !c.allows(action) {
+		writeError(w, errorf(http.StatusForbidden, "token %s can't %s licenses", c.Name, action))
+		return
+	}
+	status, v, err := fn()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, status, v)
+}
+
+// IssueRequest is the body of an issue request. The validity period is set
+// by the expires claim, or by Duration from the not before date or now.
+type IssueRequest struct {
+	Claims   lk.Claims `json:"claims"`
+	Duration Duration  `json:"duration,omitempty"`
+}
+
+// RenewRequest is the body of a renew request. The new expiration date is
+// Expires, or Duration after the current expiration date or now if it is
+// later.
+type RenewRequest struct {
+	Expires  time.Time `json:"expires,omitempty"`
+	Duration Duration  `json:"duration,omitempty"`
+}
+
+// RevokeRequest is the optional body of a revoke request.
+type RevokeRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// checkScope checks that the license is in the scope of the token.
+func checkScope(c *credential, product string, start, expires time.Time) error {
+	if !c.allowsProduct(product) {
+		return errorf(http.StatusForbidden, "token %s can't access the product %q", c.Name, product)
+	}
+	if c.MaxDuration > 0 {
+		if expires.IsZero() {
+			return errorf(http.StatusForbidden, "token %s can't issue licenses that never expire", c.Name)
+		}
+		if expires.Sub(start) > time.Duration(c.MaxDuration) {
+			return errorf(http.StatusForbidden, "token %s can't issue licenses valid more than %s", c.Name, time.Duration(c.MaxDuration))
+		}
+	}
+	return nil
+}
+
+// register signs, records and returns the license.
+func (s *Server) register(c *credential, l *lk.License) (*lk.IssuedLicense, error) {
+	il, err := s.cfg.Registry.Add(l)
+	if err != nil {
+		return nil, err
+	}
+	if s.cfg.Ledger != nil {
+		if _, err := s.cfg.Ledger.Record(l, c.Name); err != nil {
+			return nil, err
+		}
+	}
+	return il, nil
+}
+
+func (s *Server) issue(c *credential, w http.ResponseWriter, r *http.Request) (int, interface{}, error) {
+	var req IssueRequest
+	if err := readJSON(w, r, &req, false); err != nil {
+		return 0, nil, err
+	}
+
+	now := s.now()
+	claims := req.Claims
+	if claims.Supersedes != nil {
+		return 0, nil, errorf(http.StatusBadRequest, "supersedes can't be set, renew the license instead")
+	}
+	if req.Duration < 0 {
+		return 0, nil, errorf(http.StatusBadRequest, "duration must be positive")
+	}
+	start := now
+	if claims.NotBefore.After(now) {
+		start = claims.NotBefore
+	}
+	if req.Duration > 0 {
+		if !claims.Expires.IsZero() {
+			return 0, nil, errorf(http.StatusBadRequest, "expires and duration can't be both set")
+		}
+		claims.Expires = start.Add(time.Duration(req.Duration))
+	}
+	claims.IssuedAt = now
+
+	if err := checkScope(c, claims.Product, start, claims.Expires); err != nil {
+		return 0, nil, err
+	}
+	if err := claims.CheckSchema(); err != nil {
+		return 0, nil, err
+	}
+
+	l, err := lk.NewClaimsLicense(s.cfg.Key, &claims)
+	if err != nil {
+		return 0, nil, err
+	}
+	il, err := s.register(c, l)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, il, nil
+}
+
+// get returns the license with the serial if it is in the scope of the
+// token.
+func (s *Server) get(c *credential, serial string) (*lk.IssuedLicense, error) {
+	il, err := s.cfg.Registry.Get(serial)
+	if err != nil {
+		return nil, err
+	}
+	if !c.allowsProduct(il.Product) {
+		// don't tell the license exists
+		return nil, fmt.Errorf("%w: %s", lk.ErrLicenseNotFound, serial)
+	}
+	return il, nil
+}
+
+func (s *Server) fetch(c *credential, serial string) (int, interface{}, error) {
+	il, err := s.get(c, serial)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, il, nil
+}
+
+func (s *Server) renew(c *credential, serial string, w http.ResponseWriter, r *http.Request) (int, interface{}, error) {
+	var req RenewRequest
+	if err := readJSON(w, r, &req, false); err != nil {
+		return 0, nil, err
+	}
+	if req.Duration < 0 {
+		return 0, nil, errorf(http.StatusBadRequest, "duration must be positive")
+	}
+	if req.Expires.IsZero() == (req.Duration == 0) {
+		return 0, nil, errorf(http.StatusBadRequest, "one of expires and duration must be set")
+	}
+
+	il, err := s.get(c, serial)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !il.RevokedAt.IsZero() {
+		return 0, nil, fmt.Errorf("%w: %s", lk.ErrRevoked, serial)
+	}
+	prev, err := il.Decode()
+	if err != nil {
+		return 0, nil, err
+	}
+	claims, err := prev.Claims()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	now := s.now()
+	expires := req.Expires
+	if req.Duration > 0 {
+		from := now
+		if claims.Expires.After(now) {
+			from = claims.Expires
+		}
+		expires = from.Add(time.Duration(req.Duration))
+	}
+	if err := checkScope(c, claims.Product, now, expires); err != nil {
+		return 0, nil, err
+	}
+
+	claims.Serial = ""
+	claims.IssuedAt = now
+	claims.NotBefore = time.Time{}
+	claims.Expires = expires.UTC()
+	if err := claims.CheckSchema(); err != nil {
+		return 0, nil, err
+	}
+
+	l, err := lk.NewRenewal(s.cfg.Key, prev, claims)
+	if err != nil {
+		return 0, nil, err
+	}
+	renewed, err := s.register(c, l)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, renewed, nil
+}
+
+func (s *Server) revoke(c *credential, serial string, w http.ResponseWriter, r *http.Request) (int, interface{}, error) {
+	var req RevokeRequest
+	if err := readJSON(w, r, &req, true); err != nil {
+		return 0, nil, err
+	}
+	if _, err := s.get(c, serial); err != nil {
+		return 0, nil, err
+	}
+	il, err := s.cfg.Registry.Revoke(serial, req.Reason, s.now())
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, il, nil
+}
+
+// Revocations is the response listing the revoked serials.
+type Revocations struct {
+	Serials []string `json:"serials"`
+}
+
+func (s *Server) revocations(c *credential) (int, interface{}, error) {
+	res := Revocations{Serials: []string{}}
+	for _, il := range s.cfg.Registry.Find(lk.RegistryQuery{}) {
+		if !il.RevokedAt.IsZero() && c.allowsProduct(il.Product) {
+			res.Serials = append(res.Serials, il.Serial)
+		}
+	}
+	return http.StatusOK, res, nil
+}
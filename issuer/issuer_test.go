@@ -0,0 +1,185 @@
+package issuer_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phox/gmsm-lk"
+	"github.com/phox/gmsm-lk/issuer"
+	"github.com/stretchr/testify/require"
+)
+
+type client struct {
+	t     *testing.T
+	url   string
+	token string
+}
+
+func (c *client) do(method, path string, body interface{}, out interface{}) int {
+	var r *bytes.Reader
+	if s, ok := body.(string); ok {
+		r = bytes.NewReader([]byte(s))
+	} else if body != nil {
+		b, err := json.Marshal(body)
+		require.NoError(c.t, err)
+		r = bytes.NewReader(b)
+	} else {
+		r = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, c.url+path, r)
+	require.NoError(c.t, err)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(c.t, err)
+	defer resp.Body.Close()
+	if out != nil {
+		require.NoError(c.t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+func newServer(t *testing.T, cfg issuer.Config) (*httptest.Server, *lk.PrivateKey) {
+	key, err := lk.NewPrivateKey()
+	require.NoError(t, err)
+	registry, err := lk.OpenRegistry(t.TempDir())
+	require.NoError(t, err)
+
+	cfg.Key, cfg.Registry = key, registry
+	srv, err := issuer.New(cfg)
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return ts, key
+}
+
+func TestServer(t *testing.T) {
+	ts, key := newServer(t, issuer.Config{Tokens: []issuer.Token{
+		{Name: "admin", Token: "admin-token"},
+		{
+			Name: "sales", Token: "sales-token",
+			Actions:     []string{issuer.ActionIssue, issuer.ActionRead},
+			Products:    []string{"editor"},
+			MaxDuration: issuer.Duration(400 * 24 * time.Hour),
+		},
+	}})
+	admin := &client{t: t, url: ts.URL, token: "admin-token"}
+	sales := &client{t: t, url: ts.URL, token: "sales-token"}
+
+	// issue
+	var il lk.IssuedLicense
+	require.Equal(t, http.StatusCreated, sales.do("POST", "/v1/licenses", map[string]interface{}{
+		"claims":   map[string]interface{}{"subject": "ACME", "product": "editor", "seats": 5},
+		"duration": "365d",
+	}, &il))
+	require.NotEmpty(t, il.Serial)
+	require.Equal(t, "ACME", il.Customer)
+
+	l, err := il.Decode()
+	require.NoError(t, err)
+	c, err := l.VerifyClaims(key.GetPublicKey(), lk.VerifyOptions{})
+	require.NoError(t, err)
+	require.Equal(t, float64(5), c.Extra["seats"])
+	require.InDelta(t, 365, c.Expires.Sub(c.IssuedAt).Hours()/24, 0.01)
+
+	// fetch
+	var fetched lk.IssuedLicense
+	require.Equal(t, http.StatusOK, sales.do("GET", "/v1/licenses/"+il.Serial, nil, &fetched))
+	require.Equal(t, il.License, fetched.License)
+	require.Equal(t, http.StatusNotFound, sales.do("GET", "/v1/licenses/unknown", nil, nil))
+
+	// renew
+	var renewed lk.IssuedLicense
+	require.Equal(t, http.StatusForbidden, sales.do("POST", "/v1/licenses/"+il.Serial+"/renew", map[string]string{"duration": "30d"}, nil))
+	require.Equal(t, http.StatusCreated, admin.do("POST", "/v1/licenses/"+il.Serial+"/renew", map[string]string{"duration": "30d"}, &renewed))
+	require.True(t, renewed.Expires.Equal(il.Expires.Add(30*24*time.Hour)))
+	rl, err := renewed.Decode()
+	require.NoError(t, err)
+	ok, err := rl.Supersedes(l)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// revoke
+	var errResp map[string]string
+	require.Equal(t, http.StatusForbidden, sales.do("POST", "/v1/licenses/"+il.Serial+"/revoke", nil, &errResp))
+	require.Contains(t, errResp["error"], "revoke")
+	require.Equal(t, http.StatusOK, admin.do("POST", "/v1/licenses/"+il.Serial+"/revoke", map[string]string{"reason": "renewed"}, nil))
+	require.Equal(t, http.StatusConflict, admin.do("POST", "/v1/licenses/"+il.Serial+"/revoke", nil, nil))
+	require.Equal(t, http.StatusConflict, admin.do("POST", "/v1/licenses/"+il.Serial+"/renew", map[string]string{"duration": "30d"}, nil))
+
+	var revocations issuer.Revocations
+	require.Equal(t, http.StatusOK, sales.do("GET", "/v1/revocations", nil, &revocations))
+	require.Equal(t, []string{il.Serial}, revocations.Serials)
+}
+
+func TestServerValidation(t *testing.T) {
+	ts, _ := newServer(t, issuer.Config{Tokens: []issuer.Token{{
+		Name: "sales", Token: "sales-token",
+		Products:    []string{"editor"},
+		MaxDuration: issuer.Duration(30 * 24 * time.Hour),
+	}}})
+	sales := &client{t: t, url: ts.URL, token: "sales-token"}
+	issue := func(body interface{}) int {
+		return sales.do("POST", "/v1/licenses", body, nil)
+	}
+
+	require.Equal(t, http.StatusUnauthorized, (&client{t: t, url: ts.URL}).do("GET", "/v1/revocations", nil, nil))
+	require.Equal(t, http.StatusUnauthorized, (&client{t: t, url: ts.URL, token: "nope"}).do("GET", "/v1/revocations", nil, nil))
+	require.Equal(t, http.StatusMethodNotAllowed, sales.do("GET", "/v1/licenses", nil, nil))
+	require.Equal(t, http.StatusNotFound, sales.do("GET", "/v2", nil, nil))
+
+	for name, tc := range map[string]struct {
+		body   interface{}
+		status int
+	}{
+		"valid":          {map[string]interface{}{"claims": map[string]string{"product": "editor"}, "duration": "7d"}, http.StatusCreated},
+		"bad json":       {"{", http.StatusBadRequest},
+		"unknown field":  {map[string]interface{}{"claim": map[string]string{}}, http.StatusBadRequest},
+		"other product":  {map[string]interface{}{"claims": map[string]string{"product": "suite"}, "duration": "7d"}, http.StatusForbidden},
+		"never expires":  {map[string]interface{}{"claims": map[string]string{"product": "editor"}}, http.StatusForbidden},
+		"too long":       {map[string]interface{}{"claims": map[string]string{"product": "editor"}, "duration": "60d"}, http.StatusForbidden},
+		"zero duration":  {map[string]interface{}{"claims": map[string]string{"product": "editor"}, "duration": "0d"}, http.StatusBadRequest},
+		"negative days":  {map[string]interface{}{"claims": map[string]string{"product": "editor"}, "duration": "-7d"}, http.StatusBadRequest},
+		"negative":       {map[string]interface{}{"claims": map[string]string{"product": "editor"}, "duration": "-1h"}, http.StatusBadRequest},
+		"overflow":       {map[string]interface{}{"claims": map[string]string{"product": "editor"}, "duration": "9999999999d"}, http.StatusBadRequest},
+		"invalid schema": {map[string]interface{}{"claims": map[string]string{"product": "editor", "email": "nope"}, "duration": "7d"}, http.StatusBadRequest},
+		"too large":      {`{"claims":{"product":"editor","x":"` + strings.Repeat("a", 70<<10) + `"}}`, http.StatusRequestEntityTooLarge},
+	} {
+		require.Equal(t, tc.status, issue(tc.body), name)
+	}
+
+	require.Equal(t, http.StatusCreated, issue(map[string]interface{}{"claims": map[string]string{"product": "editor", "serial": "s1"}, "duration": "7d"}))
+	require.Equal(t, http.StatusConflict, issue(map[string]interface{}{"claims": map[string]string{"product": "editor", "serial": "s1"}, "duration": "7d"}))
+	require.Equal(t, http.StatusBadRequest, sales.do("POST", "/v1/licenses/s1/renew", map[string]string{"duration": "-7d"}, nil))
+	require.Equal(t, http.StatusBadRequest, sales.do("POST", "/v1/licenses/s1/renew", map[string]string{"duration": "0s"}, nil))
+}
+
+func TestServerRateLimit(t *testing.T) {
+	ts, _ := newServer(t, issuer.Config{
+		Tokens:    []issuer.Token{{Name: "a", Token: "a"}, {Name: "b", Token: "b"}},
+		RateLimit: 0.01,
+		Burst:     2,
+	})
+	a := &client{t: t, url: ts.URL, token: "a"}
+	b := &client{t: t, url: ts.URL, token: "b"}
+
+	require.Equal(t, http.StatusOK, a.do("GET", "/v1/revocations", nil, nil))
+	require.Equal(t, http.StatusOK, a.do("GET", "/v1/revocations", nil, nil))
+	require.Equal(t, http.StatusTooManyRequests, a.do("GET", "/v1/revocations", nil, nil))
+	require.Equal(t, http.StatusOK, b.do("GET", "/v1/revocations", nil, nil))
+}
+
+func TestOpenAPI(t *testing.T) {
+	ts, _ := newServer(t, issuer.Config{})
+	var doc map[string]interface{}
+	require.Equal(t, http.StatusOK, (&client{t: t, url: ts.URL}).do("GET", "/openapi.json", nil, &doc))
+	require.Equal(t, "3.0.3", doc["openapi"])
+	require.Contains(t, doc["paths"], "/v1/licenses/{serial}/renew")
+}
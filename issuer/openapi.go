@@ -0,0 +1,136 @@
+package issuer
+
+// openAPI is the OpenAPI description of the service served at /openapi.json.
+const openAPI = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "gmsm-lk license issuer",
+    "version": "1.0.0",
+    "description": "Issues, fetches, renews and revokes SM2 signed licenses."
+  },
+  "components": {
+    "securitySchemes": {
+      "bearer": {"type": "http", "scheme": "bearer"}
+    },
+    "schemas": {
+      "Claims": {
+        "type": "object",
+        "description": "Claims of the license, the unknown properties are kept as extra claims.",
+        "properties": {
+          "serial": {"type": "string", "description": "Random if empty."},
+          "subject": {"type": "string"},
+          "product": {"type": "string"},
+          "machine": {"type": "string"},
+          "not_before": {"type": "string", "format": "date-time"},
+          "expires": {"type": "string", "format": "date-time"},
+          "email": {"type": "string", "format": "email"},
+          "features": {"type": "array", "items": {"type": "string"}},
+          "quotas": {"type": "object", "additionalProperties": {"type": "integer", "minimum": 0}}
+        },
+        "additionalProperties": true
+      },
+      "IssueRequest": {
+        "type": "object",
+        "properties": {
+          "claims": {"$ref": "#/components/schemas/Claims"},
+          "duration": {"type": "string", "example": "365d", "description": "Validity period, instead of claims.expires."}
+        },
+        "required": ["claims"]
+      },
+      "RenewRequest": {
+        "type": "object",
+        "description": "One of expires and duration must be set. The duration is added to the current expiration date, or to now if it is later.",
+        "properties": {
+          "expires": {"type": "string", "format": "date-time"},
+          "duration": {"type": "string", "example": "365d"}
+        }
+      },
+      "RevokeRequest": {
+        "type": "object",
+        "properties": {"reason": {"type": "string"}}
+      },
+      "IssuedLicense": {
+        "type": "object",
+        "properties": {
+          "serial": {"type": "string"},
+          "customer": {"type": "string"},
+          "product": {"type": "string"},
+          "issued_at": {"type": "string", "format": "date-time"},
+          "expires": {"type": "string", "format": "date-time"},
+          "license": {"type": "string", "description": "Base32 encoded license."},
+          "revoked_at": {"type": "string", "format": "date-time"},
+          "revoke_reason": {"type": "string"}
+        }
+      },
+      "Revocations": {
+        "type": "object",
+        "properties": {"serials": {"type": "array", "items": {"type": "string"}}}
+      },
+      "Error": {
+        "type": "object",
+        "properties": {"error": {"type": "string"}}
+      }
+    },
+    "responses": {
+      "Error": {
+        "description": "Invalid request (400), missing or invalid token (401), out of the scope of the token (403), unknown license (404), serial already used or license revoked (409), rate limit exceeded (429).",
+        "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}
+      }
+    }
+  },
+  "security": [{"bearer": []}],
+  "paths": {
+    "/v1/licenses": {
+      "post": {
+        "summary": "Issues a license.",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/IssueRequest"}}}},
+        "responses": {
+          "201": {"description": "Issued license.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/IssuedLicense"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/v1/licenses/{serial}": {
+      "parameters": [{"name": "serial", "in": "path", "required": true, "schema": {"type": "string"}}],
+      "get": {
+        "summary": "Fetches a license.",
+        "responses": {
+          "200": {"description": "The license.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/IssuedLicense"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/v1/licenses/{serial}/renew": {
+      "parameters": [{"name": "serial", "in": "path", "required": true, "schema": {"type": "string"}}],
+      "post": {
+        "summary": "Issues a license superseding the license.",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/RenewRequest"}}}},
+        "responses": {
+          "201": {"description": "Renewed license.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/IssuedLicense"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/v1/licenses/{serial}/revoke": {
+      "parameters": [{"name": "serial", "in": "path", "required": true, "schema": {"type": "string"}}],
+      "post": {
+        "summary": "Revokes a license.",
+        "requestBody": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/RevokeRequest"}}}},
+        "responses": {
+          "200": {"description": "Revoked license.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/IssuedLicense"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/v1/revocations": {
+      "get": {
+        "summary": "Lists the revoked serials.",
+        "responses": {
+          "200": {"description": "Revoked serials.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Revocations"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    }
+  }
+}
+`
@@ -0,0 +1,154 @@
+package issuer
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emmansun/gmsm/sm3"
+)
+
+// The actions a token can be allowed.
+const (
+	ActionIssue  = "issue"
+	ActionRead   = "read"
+	ActionRenew  = "renew"
+	ActionRevoke = "revoke"
+)
+
+// Duration is a time.Duration marshalled as a string: a Go duration (36h) or
+// a number of days (365d).
+type Duration time.Duration
+
+// ParseDuration parses a Go duration or a number of days. The duration must
+// be positive.
+func ParseDuration(s string) (Duration, error) {
+	if n, err := strconv.ParseInt(strings.TrimSuffix(s, "d"), 10, 64); err == nil && strings.HasSuffix(s, "d") {
+		if n <= 0 || n > math.MaxInt64/int64(24*time.Hour) {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return Duration(time.Duration(n) * 24 * time.Hour), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return Duration(d), nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. An empty string is a zero
+// duration, other durations must be positive.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		*d = 0
+		return nil
+	}
+	v, err := ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// Token is a bearer token of the server and its scope.
+type Token struct {
+	// Name identifies the token in the logs and in the ledger.
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	// Actions lists the allowed actions, all if empty.
+	Actions []string `json:"actions,omitempty"`
+	// Products lists the products the token can access, all if empty.
+	Products []string `json:"products,omitempty"`
+	// MaxDuration is the longest validity period of the licenses issued or
+	// renewed with the token, unlimited if zero.
+	MaxDuration Duration `json:"max_duration,omitempty"`
+}
+
+// LoadTokens reads the tokens from a json file holding a list of Token.
+func LoadTokens(path string) ([]Token, error) {
+	b, err := os.ReadFile(path) // #nosec G304 -- path is chosen by the operator
+	if err != nil {
+		return nil, err
+	}
+	var tokens []Token
+	if err := json.Unmarshal(b, &tokens); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return tokens, nil
+}
+
+func (t *Token) allows(action string) bool {
+	return len(t.Actions) == 0 || contains(t.Actions, action)
+}
+
+func (t *Token) allowsProduct(product string) bool {
+	return len(t.Products) == 0 || contains(t.Products, product)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// credential is a token known by the server, compared by its SM3 digest in
+// constant time.
+type credential struct {
+	Token
+	digest [32]byte
+	bucket bucket
+}
+
+func (c *credential) match(digest [32]byte) bool {
+	return subtle.ConstantTimeCompare(c.digest[:], digest[:]) == 1
+}
+
+func newCredential(t Token) *credential {
+	return &credential{Token: t, digest: sm3.Sum([]byte(t.Token))}
+}
+
+// bucket is a token bucket rate limiter.
+type bucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// take takes a token from the bucket refilled at rate per second up to
+// burst. It returns the time to wait for the next token if there is none.
+func (b *bucket) take(now time.Time, rate float64, burst int) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.last.IsZero() {
+		b.tokens = float64(burst)
+	} else {
+		b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
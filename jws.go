@@ -0,0 +1,186 @@
+package lk
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/emmansun/gmsm/sm2"
+)
+
+// AlgSM2SM3 is the JWS algorithm name of SM2 signatures over SM3.
+const AlgSM2SM3 = "SM2SM3"
+
+var (
+	// ErrInvalidToken is returned when a compact token is not well formed.
+	ErrInvalidToken = fmt.Errorf("%w: invalid token", ErrMalformed)
+
+	// ErrUnsupportedAlgorithm is returned when a token is not signed with
+	// AlgSM2SM3.
+	ErrUnsupportedAlgorithm = errors.New("lk: unsupported algorithm")
+)
+
+type jwsHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid"`
+}
+
+// jwtNames maps the json names of Claims to the registered JWT claim names.
+var jwtNames = map[string]string{
+	"serial":     "jti",
+	"subject":    "sub",
+	"issued_at":  "iat",
+	"not_before": "nbf",
+	"expires":    "exp",
+}
+
+var b64url = base64.RawURLEncoding
+
+// NewJWT signs the claims as a compact JWS (header.payload.signature) with
+// the SM2SM3 algorithm. The times are mapped to the exp, nbf and iat numeric
+// dates, the serial to jti and the subject to sub.
+func NewJWT(k Signer, c *Claims) (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	m := map[string]interface{}{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&m); err != nil {
+		return "", err
+	}
+
+	for from, to := range jwtNames {
+		v, ok := m[from]
+		if !ok {
+			continue
+		}
+		delete(m, from)
+		if s, ok := v.(string); ok && to != "jti" && to != "sub" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return "", err
+			}
+			v = t.Unix()
+		}
+		m[to] = v
+	}
+
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	header, err := json.Marshal(jwsHeader{
+		Alg: AlgSM2SM3,
+		Typ: "JWT",
+		Kid: k.GetPublicKey().Fingerprint(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	input := b64url.EncodeToString(header) + "." + b64url.EncodeToString(payload)
+	r, s, err := k.SignMessage([]byte(input))
+	if err != nil {
+		return "", err
+	}
+
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return input + "." + b64url.EncodeToString(sig), nil
+}
+
+// ToJWT signs the claims of the license as a compact JWS. The license data
+// must be Claims.
+func (l *License) ToJWT(k Signer) (string, error) {
+	c, err := l.Claims()
+	if err != nil {
+		return "", err
+	}
+	return NewJWT(k, c)
+}
+
+// ParseJWT verifies a compact JWS created by NewJWT and returns its claims.
+// The algorithm must be SM2SM3 and the key id must match the public key. The
+// validity dates are not checked.
+func ParseJWT(token string, k *PublicKey) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	hb, err := b64url.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var h jwsHeader
+	if err := json.Unmarshal(hb, &h); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if h.Alg != AlgSM2SM3 {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	if h.Kid != k.Fingerprint() {
+		return nil, ErrUnknownKey
+	}
+
+	sig, err := b64url.DecodeString(parts[2])
+	if err != nil || len(sig) != 64 {
+		return nil, ErrInvalidToken
+	}
+	pub, err := sm2.NewPublicKey(k.ToBytes())
+	if err != nil {
+		return nil, err
+	}
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	if !sm2.VerifyWithSM2(pub, nil, []byte(parts[0]+"."+parts[1]), r, s) {
+		return nil, ErrBadSignature
+	}
+
+	pb, err := b64url.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	m := map[string]interface{}{}
+	d := json.NewDecoder(bytes.NewReader(pb))
+	d.UseNumber()
+	if err := d.Decode(&m); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	for to, from := range jwtNames {
+		v, ok := m[from]
+		if !ok {
+			continue
+		}
+		delete(m, from)
+		if n, ok := v.(json.Number); ok {
+			sec, err := n.Int64()
+			if err != nil {
+				return nil, ErrInvalidToken
+			}
+			v = time.Unix(sec, 0).UTC().Format(time.RFC3339)
+		}
+		m[to] = v
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	c := &Claims{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, ErrInvalidToken
+	}
+	return c, nil
+}
@@ -0,0 +1,65 @@
+package lk_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"time"
+
+	lk "github.com/phox/gmsm-lk"
+)
+
+func (s *Suite) TestJWT() {
+	privateKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+	wrongKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+
+	c := &lk.Claims{
+		Serial:  "42",
+		Subject: "user@example.com",
+		Expires: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+		Extra:   map[string]interface{}{"seats": float64(10)},
+	}
+	token, err := lk.NewJWT(privateKey, c)
+	s.Require().NoError(err)
+
+	s.Run("should use registered claim names", func() {
+		parts := strings.Split(token, ".")
+		s.Require().Len(parts, 3)
+
+		header, err := base64.RawURLEncoding.DecodeString(parts[0])
+		s.Require().NoError(err)
+		s.Require().JSONEq(`{"alg":"SM2SM3","typ":"JWT","kid":"`+
+			privateKey.GetPublicKey().Fingerprint()+`"}`, string(header))
+
+		payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+		s.Require().NoError(err)
+		s.Require().JSONEq(`{"jti":"42","sub":"user@example.com","exp":1893553445,"seats":10}`, string(payload))
+	})
+
+	s.Run("should parse a token", func() {
+		c2, err := lk.ParseJWT(token, privateKey.GetPublicKey())
+		s.Require().NoError(err)
+		s.Require().Equal(c.Serial, c2.Serial)
+		s.Require().Equal(c.Subject, c2.Subject)
+		s.Require().True(c.Expires.Equal(c2.Expires))
+		s.Require().Equal(c.Extra, c2.Extra)
+	})
+
+	s.Run("should reject invalid tokens", func() {
+		_, err := lk.ParseJWT(token, wrongKey.GetPublicKey())
+		s.Require().ErrorIs(err, lk.ErrUnknownKey)
+
+		_, err = lk.ParseJWT("a.b", privateKey.GetPublicKey())
+		s.Require().ErrorIs(err, lk.ErrInvalidToken)
+
+		none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+		_, err = lk.ParseJWT(none+token[strings.Index(token, "."):], privateKey.GetPublicKey())
+		s.Require().ErrorIs(err, lk.ErrUnsupportedAlgorithm)
+
+		parts := strings.Split(token, ".")
+		other := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin"}`))
+		_, err = lk.ParseJWT(parts[0]+"."+other+"."+parts[2], privateKey.GetPublicKey())
+		s.Require().ErrorIs(err, lk.ErrBadSignature)
+	})
+}
@@ -1,6 +1,7 @@
 package lk
 
 import (
+	"bytes"
 	"crypto/rand"
 	"encoding/base32"
 	"encoding/base64"
@@ -9,6 +10,7 @@ import (
 	"math/big"
 
 	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/sm3"
 )
 
 // ErrInvalidPublicKey is returned when the public key is invalid.
@@ -25,6 +27,16 @@ type pkContainer struct {
 	D   *big.Int
 }
 
+// Signer signs messages with an SM2 private key. PrivateKey implements it,
+// and so does the client of a signing agent holding the key in another
+// process.
+type Signer interface {
+	// GetPublicKey returns the public key of the signing key.
+	GetPublicKey() *PublicKey
+	// SignMessage signs the message with SM2 and the default user id.
+	SignMessage(msg []byte) (r, s *big.Int, err error)
+}
+
 // NewPrivateKey generates a new SM2 private key.
 func NewPrivateKey() (*PrivateKey, error) {
 	tmp, err := sm2.GenerateKey(rand.Reader)
@@ -38,6 +50,11 @@ func (k *PrivateKey) toSM2() *sm2.PrivateKey {
 	return k.key
 }
 
+// SignMessage signs the message with SM2 and the default user id.
+func (k *PrivateKey) SignMessage(msg []byte) (r, s *big.Int, err error) {
+	return sm2.SignWithSM2(rand.Reader, &k.key.PrivateKey, nil, msg)
+}
+
 // ToBytes transforms the private key to a []byte.
 func (k *PrivateKey) ToBytes() ([]byte, error) {
 	// 使用未压缩格式序列化公钥 (04 || X || Y)
@@ -88,10 +105,14 @@ func PrivateKeyFromBytes(b []byte) (*PrivateKey, error) {
 		return nil, err
 	}
 
+	if err := checkScalar(c.D); err != nil {
+		return nil, err
+	}
+
 	// 使用 sm2.NewPrivateKeyFromInt 创建私钥
 	sm2Priv, err := sm2.NewPrivateKeyFromInt(c.D)
 	if err != nil {
-		return nil, err
+		return nil, &DecodeError{Encoding: "private key", Err: err}
 	}
 
 	// 验证公钥是否匹配
@@ -101,10 +122,8 @@ func PrivateKeyFromBytes(b []byte) (*PrivateKey, error) {
 	sm2Priv.PublicKey.Y.FillBytes(expectedPub[33:65])
 
 	// 检查存储的公钥与计算出的公钥是否一致
-	for i := range expectedPub {
-		if expectedPub[i] != c.Pub[i] {
-			return nil, ErrInvalidPublicKey
-		}
+	if !bytes.Equal(expectedPub, c.Pub) {
+		return nil, &DecodeError{Encoding: "private key", Err: ErrInvalidPublicKey}
 	}
 
 	return &PrivateKey{key: sm2Priv}, nil
@@ -113,7 +132,7 @@ func PrivateKeyFromBytes(b []byte) (*PrivateKey, error) {
 // PrivateKeyFromB64String returns a private key from a base64 encoded
 // string.
 func PrivateKeyFromB64String(str string) (*PrivateKey, error) {
-	b, err := base64.StdEncoding.DecodeString(str)
+	b, err := decodeB64(str)
 	if err != nil {
 		return nil, err
 	}
@@ -123,7 +142,7 @@ func PrivateKeyFromB64String(str string) (*PrivateKey, error) {
 // PrivateKeyFromB32String returns a private key from a base32 encoded
 // string.
 func PrivateKeyFromB32String(str string) (*PrivateKey, error) {
-	b, err := base32.StdEncoding.DecodeString(str)
+	b, err := decodeB32(str)
 	if err != nil {
 		return nil, err
 	}
@@ -133,7 +152,7 @@ func PrivateKeyFromB32String(str string) (*PrivateKey, error) {
 // PrivateKeyFromHexString returns a private key from a hexadecimal encoded
 // string.
 func PrivateKeyFromHexString(str string) (*PrivateKey, error) {
-	b, err := hex.DecodeString(str)
+	b, err := decodeHex(str)
 	if err != nil {
 		return nil, err
 	}
@@ -166,6 +185,13 @@ func (k *PublicKey) ToBytes() []byte {
 	return pkBytes
 }
 
+// Fingerprint returns the hexadecimal SM3 digest of the public key. It is
+// used as the key id of the signed documents.
+func (k *PublicKey) Fingerprint() string {
+	h := sm3.Sum(k.ToBytes())
+	return hex.EncodeToString(h[:])
+}
+
 // ToB64String transforms the public key to a base64 string.
 func (k *PublicKey) ToB64String() string {
 	return base64.StdEncoding.EncodeToString(
@@ -191,7 +217,7 @@ func (k *PublicKey) ToHexString() string {
 // 支持未压缩格式 (04 || X || Y) 的公钥
 func PublicKeyFromBytes(b []byte) (*PublicKey, error) {
 	if len(b) != 65 || b[0] != 0x04 {
-		return nil, ErrInvalidPublicKey
+		return nil, &DecodeError{Encoding: "public key", Err: ErrInvalidPublicKey}
 	}
 
 	x := new(big.Int).SetBytes(b[1:33])
@@ -200,7 +226,7 @@ func PublicKeyFromBytes(b []byte) (*PublicKey, error) {
 	// 使用 sm2.P256() 验证点是否在曲线上
 	curve := sm2.P256()
 	if !curve.IsOnCurve(x, y) {
-		return nil, ErrInvalidPublicKey
+		return nil, &DecodeError{Encoding: "public key", Err: ErrInvalidPublicKey}
 	}
 
 	return &PublicKey{
@@ -212,7 +238,7 @@ func PublicKeyFromBytes(b []byte) (*PublicKey, error) {
 // PublicKeyFromB64String returns a public key from a base64 encoded
 // string.
 func PublicKeyFromB64String(str string) (*PublicKey, error) {
-	b, err := base64.StdEncoding.DecodeString(str)
+	b, err := decodeB64(str)
 	if err != nil {
 		return nil, err
 	}
@@ -223,7 +249,7 @@ func PublicKeyFromB64String(str string) (*PublicKey, error) {
 // PublicKeyFromB32String returns a public key from a base32 encoded
 // string.
 func PublicKeyFromB32String(str string) (*PublicKey, error) {
-	b, err := base32.StdEncoding.DecodeString(str)
+	b, err := decodeB32(str)
 	if err != nil {
 		return nil, err
 	}
@@ -234,7 +260,7 @@ func PublicKeyFromB32String(str string) (*PublicKey, error) {
 // PublicKeyFromHexString returns a public key from a hexadecimal encoded
 // string.
 func PublicKeyFromHexString(str string) (*PublicKey, error) {
-	b, err := hex.DecodeString(str)
+	b, err := decodeHex(str)
 	if err != nil {
 		return nil, err
 	}
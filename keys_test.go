@@ -34,6 +34,15 @@ func (s *Suite) TestKeys() {
 		s.Require().Nil(k2)
 	})
 
+	s.Run("should test public key fingerprint", func() {
+		other, err := lk.NewPrivateKey()
+		s.Require().NoError(err)
+
+		s.Require().Len(k.GetPublicKey().Fingerprint(), 64)
+		s.Require().Equal(k.GetPublicKey().Fingerprint(), k.GetPublicKey().Fingerprint())
+		s.Require().NotEqual(k.GetPublicKey().Fingerprint(), other.GetPublicKey().Fingerprint())
+	})
+
 	tc := []struct {
 		name               string
 		privateKeyToString func(k *lk.PrivateKey) (string, error)
@@ -0,0 +1,361 @@
+package lk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/sm3"
+)
+
+// The kinds of the ledger entries.
+const (
+	LedgerIssue      = "issue"
+	LedgerCheckpoint = "checkpoint"
+)
+
+// ErrLedgerTampered is returned when a ledger was modified: an entry was
+// changed, removed, reordered or a checkpoint signature doesn't match.
+var ErrLedgerTampered = errors.New("lk: ledger tampered")
+
+// ErrLedgerTorn is returned when the last line of a ledger is incomplete,
+// typically because the process crashed while appending it. The entry was
+// never acknowledged: remove the incomplete line to open the ledger.
+var ErrLedgerTorn = errors.New("lk: ledger has an incomplete last line")
+
+// ErrLedgerLocked is returned when a ledger is already open by another
+// Ledger, in this process or another one.
+var ErrLedgerLocked = errors.New("lk: ledger is locked")
+
+// LedgerEntry is a line of a Ledger. Each entry is linked to the previous
+// one by its SM3 hash. Checkpoint entries are signed with SM2.
+type LedgerEntry struct {
+	Seq        uint64    `json:"seq"`
+	Kind       string    `json:"kind"`
+	Time       time.Time `json:"time"`
+	Serial     string    `json:"serial,omitempty"`
+	ClaimsHash string    `json:"claims_hash,omitempty"`
+	KeyID      string    `json:"kid"`
+	Operator   string    `json:"operator,omitempty"`
+	Prev       string    `json:"prev"`
+	Hash       string    `json:"hash"`
+	R          string    `json:"r,omitempty"`
+	S          string    `json:"s,omitempty"`
+}
+
+// hash returns the SM3 digest of the entry fields but the hash and the
+// signature, each field is length prefixed.
+func (e *LedgerEntry) hash() []byte {
+	h := sm3.New()
+	var seq [8]byte
+	binary.BigEndian.PutUint64(seq[:], e.Seq)
+	for _, f := range [][]byte{
+		[]byte("gmsm-lk/ledger/v1"),
+		seq[:],
+		[]byte(e.Kind),
+		[]byte(e.Time.UTC().Format(time.RFC3339Nano)),
+		[]byte(e.Serial),
+		[]byte(e.ClaimsHash),
+		[]byte(e.KeyID),
+		[]byte(e.Operator),
+		[]byte(e.Prev),
+	} {
+		var n [4]byte
+		binary.BigEndian.PutUint32(n[:], uint32(len(f)))
+		h.Write(n[:])
+		h.Write(f)
+	}
+	return h.Sum(nil)
+}
+
+// Ledger is an append-only record of the issued licenses, stored as json
+// lines. It is safe for concurrent use, and the file is locked while it is
+// open so that a single Ledger appends to it.
+//
+// The checkpoints don't detect a truncation: removing the last entries,
+// checkpoints included, leaves a valid ledger. Only a head hash kept out of
+// the ledger and given to VerifyLedger catches it.
+type Ledger struct {
+	// CheckpointEvery is the number of issue entries after which a
+	// checkpoint is appended automatically, never if not positive or if
+	// the ledger has no key.
+	CheckpointEvery int
+
+	mu      sync.Mutex
+	f       *os.File
+	key     Signer
+	head    *LedgerEntry
+	pending int
+}
+
+// OpenLedger opens or creates the ledger file and locks it until Close, it
+// returns ErrLedgerLocked if it is already open. The hash chain is checked
+// before anything is appended. The key signs the checkpoints, it may be nil
+// if no checkpoint is written.
+func OpenLedger(path string, k Signer) (*Ledger, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600) // #nosec G304 -- path is chosen by the application
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if pk, ok := k.(*PrivateKey); ok && pk == nil {
+		k = nil
+	}
+	lg := &Ledger{f: f, key: k}
+	if err := readLedger(f, func(e *LedgerEntry) error {
+		lg.head = e
+		if e.Kind == LedgerCheckpoint {
+			lg.pending = 0
+		} else {
+			lg.pending++
+		}
+		return nil
+	}); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return lg, nil
+}
+
+// readLedger reads the entries and checks the hash chain. A last line with
+// no line break is reported as ErrLedgerTorn.
+func readLedger(r io.Reader, fn func(*LedgerEntry) error) error {
+	br := bufio.NewReader(r)
+
+	var prev *LedgerEntry
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) != 0 {
+				return fmt.Errorf("%w: entry %d", ErrLedgerTorn, seqAfter(prev))
+			}
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		e := &LedgerEntry{}
+		if err := json.Unmarshal(line, e); err != nil {
+			return fmt.Errorf("%w: entry %d: %v", ErrLedgerTampered, seqAfter(prev), err)
+		}
+		if e.Seq != seqAfter(prev) {
+			return fmt.Errorf("%w: entry %d found after entry %d", ErrLedgerTampered, e.Seq, seqAfter(prev)-1)
+		}
+		if prevHash := headHash(prev); e.Prev != prevHash {
+			return fmt.Errorf("%w: entry %d is not linked to the previous entry", ErrLedgerTampered, e.Seq)
+		}
+		if hex.EncodeToString(e.hash()) != e.Hash {
+			return fmt.Errorf("%w: entry %d was modified", ErrLedgerTampered, e.Seq)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+		prev = e
+	}
+}
+
+func seqAfter(e *LedgerEntry) uint64 {
+	if e == nil {
+		return 1
+	}
+	return e.Seq + 1
+}
+
+func headHash(e *LedgerEntry) string {
+	if e == nil {
+		return ""
+	}
+	return e.Hash
+}
+
+// Head returns the last entry of the ledger, nil if it is empty. Keep its
+// hash out of the ledger to detect a truncation with VerifyLedger.
+func (lg *Ledger) Head() *LedgerEntry {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	return lg.head
+}
+
+// append links, hashes and writes an entry.
+func (lg *Ledger) append(e *LedgerEntry) error {
+	e.Seq = seqAfter(lg.head)
+	e.Prev = headHash(lg.head)
+	e.Time = e.Time.UTC()
+	h := e.hash()
+	e.Hash = hex.EncodeToString(h)
+
+	if e.Kind == LedgerCheckpoint {
+		r, s, err := lg.key.SignMessage(h)
+		if err != nil {
+			return err
+		}
+		e.R, e.S = intToHex(r), intToHex(s)
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := lg.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	if err := lg.f.Sync(); err != nil {
+		return err
+	}
+
+	lg.head = e
+	if e.Kind == LedgerCheckpoint {
+		lg.pending = 0
+	} else {
+		lg.pending++
+	}
+	return nil
+}
+
+// Record appends an issue entry for the license: its serial, the SM3 hash
+// of its data, its key id and the operator who issued it.
+func (lg *Ledger) Record(l *License, operator string) (*LedgerEntry, error) {
+	e := &LedgerEntry{
+		Kind:     LedgerIssue,
+		Time:     time.Now(),
+		KeyID:    l.KeyID,
+		Operator: operator,
+	}
+	if c, err := l.Claims(); err == nil {
+		e.Serial = c.Serial
+	}
+	h := sm3.Sum(l.Data)
+	e.ClaimsHash = hex.EncodeToString(h[:])
+
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	if err := lg.append(e); err != nil {
+		return nil, err
+	}
+	if lg.key != nil && lg.CheckpointEvery > 0 && lg.pending >= lg.CheckpointEvery {
+		if _, err := lg.checkpoint(); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Checkpoint appends an entry signing the head of the ledger.
+func (lg *Ledger) Checkpoint() (*LedgerEntry, error) {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	return lg.checkpoint()
+}
+
+func (lg *Ledger) checkpoint() (*LedgerEntry, error) {
+	if lg.key == nil {
+		return nil, errors.New("lk: the ledger has no key to sign checkpoints")
+	}
+	e := &LedgerEntry{
+		Kind:  LedgerCheckpoint,
+		Time:  time.Now(),
+		KeyID: lg.key.GetPublicKey().Fingerprint(),
+	}
+	if err := lg.append(e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Close closes the ledger file and releases its lock.
+func (lg *Ledger) Close() error {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	return lg.f.Close()
+}
+
+// LedgerReport is the result of VerifyLedger.
+type LedgerReport struct {
+	Entries     int
+	Issued      int
+	Checkpoints int
+	// Head is the last entry, nil if the ledger is empty.
+	Head *LedgerEntry
+	// LastCheckpoint is the last checkpoint entry, nil if there is none.
+	LastCheckpoint *LedgerEntry
+	// Unsealed is the number of entries after the last checkpoint.
+	Unsealed int
+}
+
+// VerifyLedger checks the hash chain of the ledger and the signature of its
+// checkpoints with the public key. If head is not empty, it is the hash of
+// an entry known to be in the ledger, typically a previous head: the ledger
+// must contain it, which detects the truncation of the last entries. Without
+// it a truncation is not detected, even across checkpoints.
+//
+// The hash chain is not keyed: the entries after the last checkpoint, counted
+// by LedgerReport.Unsealed, can be rewritten, or the ledger truncated back to
+// a checkpoint and new entries forged, without failing the verification.
+// Only the checkpoints are signed.
+func VerifyLedger(r io.Reader, k *PublicKey, head string) (*LedgerReport, error) {
+	pub, err := sm2.NewPublicKey(k.ToBytes())
+	if err != nil {
+		return nil, err
+	}
+
+	report := &LedgerReport{}
+	headFound := head == ""
+	err = readLedger(r, func(e *LedgerEntry) error {
+		report.Entries++
+		report.Head = e
+		report.Unsealed++
+		if e.Hash == head {
+			headFound = true
+		}
+
+		switch e.Kind {
+		case LedgerIssue:
+			report.Issued++
+		case LedgerCheckpoint:
+			if e.KeyID != k.Fingerprint() {
+				return fmt.Errorf("%w: checkpoint %d: %v", ErrLedgerTampered, e.Seq, &KeyError{KeyID: e.KeyID, Expected: k.Fingerprint()})
+			}
+			h, _ := hex.DecodeString(e.Hash)
+			rr, err1 := hexToInt(e.R)
+			ss, err2 := hexToInt(e.S)
+			if err1 != nil || err2 != nil || checkSignature(rr, ss) != nil || !sm2.VerifyWithSM2(pub, nil, h, rr, ss) {
+				return fmt.Errorf("%w: checkpoint %d: %v", ErrLedgerTampered, e.Seq, ErrBadSignature)
+			}
+			report.Checkpoints++
+			report.LastCheckpoint = e
+			report.Unsealed = 0
+		default:
+			return fmt.Errorf("%w: entry %d has an unknown kind %q", ErrLedgerTampered, e.Seq, e.Kind)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !headFound {
+		return nil, fmt.Errorf("%w: the ledger was truncated before %s", ErrLedgerTampered, head)
+	}
+	return report, nil
+}
+
+// VerifyLedgerFile is VerifyLedger on a file.
+func VerifyLedgerFile(path string, k *PublicKey, head string) (*LedgerReport, error) {
+	b, err := os.ReadFile(path) // #nosec G304 -- path is chosen by the application
+	if err != nil {
+		return nil, err
+	}
+	return VerifyLedger(bytes.NewReader(b), k, head)
+}
@@ -0,0 +1,126 @@
+package lk_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	lk "github.com/phox/gmsm-lk"
+)
+
+func (s *Suite) TestLedger() {
+	privateKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+	publicKey := privateKey.GetPublicKey()
+
+	// newLedger writes a ledger of 5 issue entries with a checkpoint every
+	// 2 entries and returns its lines.
+	newLedger := func() (string, []string) {
+		path := filepath.Join(s.T().TempDir(), "ledger.jsonl")
+		lg, err := lk.OpenLedger(path, privateKey)
+		s.Require().NoError(err)
+		lg.CheckpointEvery = 2
+
+		for i := 0; i < 5; i++ {
+			l, err := lk.NewClaimsLicense(privateKey, &lk.Claims{Product: "editor"})
+			s.Require().NoError(err)
+			e, err := lg.Record(l, "alice")
+			s.Require().NoError(err)
+			s.Require().Equal(lk.LedgerIssue, e.Kind)
+		}
+		s.Require().NoError(lg.Close())
+
+		b, err := os.ReadFile(path)
+		s.Require().NoError(err)
+		return path, strings.Split(strings.TrimSpace(string(b)), "\n")
+	}
+	write := func(path string, lines []string) {
+		s.Require().NoError(os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600))
+	}
+
+	s.Run("should record and verify", func() {
+		path, lines := newLedger()
+		s.Require().Len(lines, 7)
+
+		report, err := lk.VerifyLedgerFile(path, publicKey, "")
+		s.Require().NoError(err)
+		s.Require().Equal(7, report.Entries)
+		s.Require().Equal(5, report.Issued)
+		s.Require().Equal(2, report.Checkpoints)
+		s.Require().Equal(1, report.Unsealed)
+		s.Require().Equal(uint64(7), report.Head.Seq)
+
+		// the ledger is continued after being reopened
+		lg, err := lk.OpenLedger(path, privateKey)
+		s.Require().NoError(err)
+		s.Require().Equal(report.Head.Hash, lg.Head().Hash)
+		cp, err := lg.Checkpoint()
+		s.Require().NoError(err)
+		s.Require().Equal(uint64(8), cp.Seq)
+		s.Require().NoError(lg.Close())
+
+		report, err = lk.VerifyLedgerFile(path, publicKey, report.Head.Hash)
+		s.Require().NoError(err)
+		s.Require().Equal(0, report.Unsealed)
+	})
+
+	s.Run("should detect tampering", func() {
+		path, lines := newLedger()
+		tampered := map[string][]string{
+			"modified":  append(append([]string{}, lines[:1]...), append([]string{strings.Replace(lines[1], "alice", "mallory", 1)}, lines[2:]...)...),
+			"removed":   append(append([]string{}, lines[:1]...), lines[2:]...),
+			"reordered": append([]string{lines[1], lines[0]}, lines[2:]...),
+		}
+		for name, t := range tampered {
+			write(path, t)
+			_, err := lk.VerifyLedgerFile(path, publicKey, "")
+			s.Require().ErrorIs(err, lk.ErrLedgerTampered, name)
+
+			_, err = lk.OpenLedger(path, privateKey)
+			s.Require().ErrorIs(err, lk.ErrLedgerTampered, name)
+		}
+	})
+
+	s.Run("should detect a truncation with the head", func() {
+		path, lines := newLedger()
+		report, err := lk.VerifyLedgerFile(path, publicKey, "")
+		s.Require().NoError(err)
+
+		write(path, lines[:5])
+		_, err = lk.VerifyLedgerFile(path, publicKey, "")
+		s.Require().NoError(err)
+		_, err = lk.VerifyLedgerFile(path, publicKey, report.Head.Hash)
+		s.Require().ErrorIs(err, lk.ErrLedgerTampered)
+	})
+
+	s.Run("should lock the ledger", func() {
+		path, _ := newLedger()
+		lg, err := lk.OpenLedger(path, privateKey)
+		s.Require().NoError(err)
+		_, err = lk.OpenLedger(path, privateKey)
+		s.Require().ErrorIs(err, lk.ErrLedgerLocked)
+
+		s.Require().NoError(lg.Close())
+		lg, err = lk.OpenLedger(path, privateKey)
+		s.Require().NoError(err)
+		s.Require().NoError(lg.Close())
+	})
+
+	s.Run("should report a torn last line", func() {
+		path, lines := newLedger()
+		s.Require().NoError(os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"+lines[6][:20]), 0600))
+
+		_, err := lk.OpenLedger(path, privateKey)
+		s.Require().ErrorIs(err, lk.ErrLedgerTorn)
+		_, err = lk.VerifyLedgerFile(path, publicKey, "")
+		s.Require().ErrorIs(err, lk.ErrLedgerTorn)
+	})
+
+	s.Run("should check the checkpoint key", func() {
+		path, _ := newLedger()
+		other, err := lk.NewPrivateKey()
+		s.Require().NoError(err)
+		_, err = lk.VerifyLedgerFile(path, other.GetPublicKey(), "")
+		s.Require().ErrorIs(err, lk.ErrLedgerTampered)
+	})
+}
@@ -1,29 +1,34 @@
 package lk
 
 import (
-	"crypto/rand"
+	"fmt"
 	"math/big"
+	"sync"
+	"time"
 
 	"github.com/emmansun/gmsm/sm2"
 	"github.com/emmansun/gmsm/sm3"
 )
 
-// License represents a license with some data and a signature.
+// License represents a license with some data and a signature. KeyID is
+// the fingerprint of the signing key, it is not covered by the signature.
 type License struct {
-	Data []byte
-	R    *big.Int
-	S    *big.Int
+	Data  []byte
+	R     *big.Int
+	S     *big.Int
+	KeyID string
 }
 
 // NewLicense create a new license and sign it using SM2.
-func NewLicense(k *PrivateKey, data []byte) (*License, error) {
+func NewLicense(k Signer, data []byte) (*License, error) {
 	l := &License{
-		Data: data,
+		Data:  data,
+		KeyID: k.GetPublicKey().Fingerprint(),
 	}
 
 	if h, err := l.hash(); err != nil {
 		return nil, err
-	} else if r, s, err := sm2.SignWithSM2(rand.Reader, &k.key.PrivateKey, nil, h); err != nil {
+	} else if r, s, err := k.SignMessage(h); err != nil {
 		return nil, err
 	} else {
 		l.R = r
@@ -44,6 +49,10 @@ func (l *License) hash() ([]byte, error) {
 
 // Verify the License with the public key using SM2
 func (l *License) Verify(k *PublicKey) (bool, error) {
+	if err := checkSignature(l.R, l.S); err != nil {
+		return false, err
+	}
+
 	h, err := l.hash()
 	if err != nil {
 		return false, err
@@ -58,6 +67,80 @@ func (l *License) Verify(k *PublicKey) (bool, error) {
 	return sm2.VerifyWithSM2(pub, nil, h, l.R, l.S), nil
 }
 
+// VerifyErr verifies the License with the public key and returns nil if it
+// is valid. The error matches ErrMalformed if the signature is missing or
+// out of range, ErrUnknownKey if the license names another key and
+// ErrBadSignature if the signature doesn't match.
+func (l *License) VerifyErr(k *PublicKey) error {
+	if err := checkSignature(l.R, l.S); err != nil {
+		return err
+	}
+	if l.KeyID != "" && l.KeyID != k.Fingerprint() {
+		return &KeyError{KeyID: l.KeyID, Expected: k.Fingerprint()}
+	}
+
+	if ok, err := l.Verify(k); err != nil {
+		return err
+	} else if !ok {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// VerifyOptions are the checks done by VerifyClaims in addition to the
+// signature.
+type VerifyOptions struct {
+	// Now is the time used to check the validity period, time.Now() if
+	// zero.
+	Now time.Time
+	// Machine is the id of the current machine, checked if not empty.
+	Machine string
+	// Revoked holds the revoked serials, checked if not nil.
+	Revoked *RevocationList
+	// Tracker rejects the licenses superseded by a license it observed,
+	// checked if not nil. The license is observed once verified.
+	Tracker *Tracker
+}
+
+// VerifyClaims verifies the signature and then the claims of the license.
+func (l *License) VerifyClaims(k *PublicKey, opts VerifyOptions) (*Claims, error) {
+	if err := l.VerifyErr(k); err != nil {
+		return nil, err
+	}
+
+	c, err := l.Claims()
+	if err != nil {
+		return nil, err
+	}
+
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if err := c.Valid(now); err != nil {
+		return nil, err
+	}
+	if opts.Machine != "" {
+		if err := c.CheckMachine(opts.Machine); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Revoked != nil {
+		if err := opts.Revoked.Check(c); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Tracker != nil {
+		if err := opts.Tracker.Check(l); err != nil {
+			return nil, err
+		}
+		if err := opts.Tracker.Observe(l); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
 // ToBytes transforms the licence to a base64 []byte.
 func (l *License) ToBytes() ([]byte, error) {
 	return toBytes(l)
@@ -104,3 +187,36 @@ func LicenseFromHexString(str string) (*License, error) {
 	l := &License{}
 	return l, fromHexString(l, str)
 }
+
+// RevocationList holds the serials of the revoked licenses. It is safe for
+// concurrent use.
+type RevocationList struct {
+	mu      sync.RWMutex
+	serials map[string]struct{}
+}
+
+// NewRevocationList returns a RevocationList with the serials revoked.
+func NewRevocationList(serials ...string) *RevocationList {
+	r := &RevocationList{serials: map[string]struct{}{}}
+	for _, s := range serials {
+		r.serials[s] = struct{}{}
+	}
+	return r
+}
+
+// Revoke adds a serial to the list.
+func (r *RevocationList) Revoke(serial string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.serials[serial] = struct{}{}
+}
+
+// Check returns ErrRevoked if the serial of the claims was revoked.
+func (r *RevocationList) Check(c *Claims) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if _, ok := r.serials[c.Serial]; ok {
+		return fmt.Errorf("%w: %s", ErrRevoked, c.Serial)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+// Package lkcli provides the license subcommands of an application using
+// kingpin: install, show, list and remove. Mount them in the application CLI:
+//
+//	app := kingpin.New("myapp", "My application.")
+//	lkcli.Mount(app, lk.NewStore("myapp"), publicKey)
+//	kingpin.MustParse(app.Parse(os.Args[1:]))
+package lkcli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/phox/gmsm-lk"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// Commands are the license subcommands mounted in an application.
+type Commands struct {
+	// Out receives the output of the commands, os.Stdout by default.
+	Out io.Writer
+	// In is read by install when no file is given, os.Stdin by default.
+	In io.Reader
+
+	store *lk.Store
+	key   *lk.PublicKey
+
+	installFile *string
+	installName *string
+	showName    *string
+	removeName  *string
+}
+
+// Mount adds the license command and its subcommands to the application.
+// The licenses are verified with the public key before being installed and
+// when they are shown.
+func Mount(app *kingpin.Application, store *lk.Store, key *lk.PublicKey) *Commands {
+	c := &Commands{Out: os.Stdout, In: os.Stdin, store: store, key: key}
+
+	license := app.Command("license", "Manage the license.")
+
+	install := license.Command("install", "Installs a license.")
+	c.installFile = install.Arg("file", "License file (if not defined then stdin).").String()
+	c.installName = install.Flag("name", "Name of the license (default to its serial).").String()
+	install.Action(func(*kingpin.ParseContext) error { return c.Install() })
+
+	show := license.Command("show", "Shows an installed license.")
+	c.showName = show.Arg("name", "Name of the license.").Required().String()
+	show.Action(func(*kingpin.ParseContext) error { return c.Show() })
+
+	list := license.Command("list", "Lists the installed licenses.")
+	list.Action(func(*kingpin.ParseContext) error { return c.List() })
+
+	remove := license.Command("remove", "Removes an installed license.")
+	c.removeName = remove.Arg("name", "Name of the license.").Required().String()
+	remove.Action(func(*kingpin.ParseContext) error { return c.Remove() })
+
+	return c
+}
+
+// Install runs the install subcommand.
+func (c *Commands) Install() error {
+	var b []byte
+	var err error
+	if *c.installFile != "" {
+		b, err = os.ReadFile(*c.installFile)
+	} else {
+		b, err = io.ReadAll(io.LimitReader(c.In, 1<<20))
+	}
+	if err != nil {
+		return err
+	}
+
+	l, _, err := (&lk.StrictDecoder{}).DecodeLicense(b)
+	if err != nil {
+		return err
+	}
+	if err := l.VerifyErr(c.key); err != nil {
+		return err
+	}
+
+	path, err := c.store.Install(*c.installName, l)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.Out, "License installed in %s\n", path)
+	return err
+}
+
+// Show runs the show subcommand.
+func (c *Commands) Show() error {
+	l, err := c.store.Load(*c.showName)
+	if err != nil {
+		return err
+	}
+
+	status := "valid"
+	if err := l.VerifyErr(c.key); err != nil {
+		status = err.Error()
+	}
+	if _, err := fmt.Fprintf(c.Out, "Signature: %s\n", status); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.Out, "%s\n", l.Data)
+	return err
+}
+
+// List runs the list subcommand.
+func (c *Commands) List() error {
+	licenses, err := c.store.List()
+	if err != nil {
+		return err
+	}
+	for _, sl := range licenses {
+		if _, err := fmt.Fprintf(c.Out, "%s\t%s\n", sl.Name, sl.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove runs the remove subcommand.
+func (c *Commands) Remove() error {
+	if err := c.store.Remove(*c.removeName); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(c.Out, "License %s removed\n", *c.removeName)
+	return err
+}
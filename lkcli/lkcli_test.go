@@ -0,0 +1,63 @@
+package lkcli_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/phox/gmsm-lk"
+	"github.com/phox/gmsm-lk/lkcli"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func TestCommands(t *testing.T) {
+	privateKey, err := lk.NewPrivateKey()
+	require.NoError(t, err)
+	license, err := lk.NewClaimsLicense(privateKey, &lk.Claims{Serial: "serial-1", Subject: "user"})
+	require.NoError(t, err)
+	b, err := license.Encode(lk.EncodingHex)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	file := filepath.Join(t.TempDir(), "license.txt")
+	require.NoError(t, os.WriteFile(file, append(b, '\n'), 0600))
+
+	app := kingpin.New("myapp", "")
+	cmds := lkcli.Mount(app, lk.NewStoreAt(dir), privateKey.GetPublicKey())
+	out := &bytes.Buffer{}
+	cmds.Out = out
+
+	run := func(args ...string) error {
+		out.Reset()
+		_, err := app.Parse(args)
+		return err
+	}
+
+	require.NoError(t, run("license", "install", file))
+	require.Contains(t, out.String(), filepath.Join(dir, "serial-1.lic"))
+
+	require.NoError(t, run("license", "list"))
+	require.True(t, strings.HasPrefix(out.String(), "serial-1\t"))
+
+	require.NoError(t, run("license", "show", "serial-1"))
+	require.Contains(t, out.String(), "Signature: valid")
+	require.Contains(t, out.String(), `"subject":"user"`)
+
+	require.NoError(t, run("license", "remove", "serial-1"))
+	require.ErrorIs(t, run("license", "show", "serial-1"), lk.ErrLicenseNotFound)
+
+	other, err := lk.NewPrivateKey()
+	require.NoError(t, err)
+	forged, err := lk.NewLicense(other, []byte("{}"))
+	require.NoError(t, err)
+	str, err := forged.ToB32String()
+	require.NoError(t, err)
+	app = kingpin.New("myapp", "")
+	cmds = lkcli.Mount(app, lk.NewStoreAt(dir), privateKey.GetPublicKey())
+	cmds.In = strings.NewReader(str)
+	cmds.Out = out
+	require.ErrorIs(t, run("license", "install"), lk.ErrUnknownKey)
+}
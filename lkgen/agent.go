@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/emmansun/gmsm/sm3"
+	"github.com/phox/gmsm-lk"
+	"github.com/phox/gmsm-lk/agent"
+)
+
+// readSigner returns the key of sign: the key file, or the agent with
+// --agent, and a function releasing it.
+func readSigner() (lk.Signer, func()) {
+	if !*signAgt {
+		if *signKey == "" {
+			log.Fatal("required argument 'key' not provided, or use --agent")
+		}
+		pk, closeKey, err := readSigningKey(*signKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return pk, closeKey
+	}
+
+	if *signKey != "" {
+		log.Fatal("a key can't be given with --agent")
+	}
+	if *deterministic || *randPath != "" {
+		log.Fatal("--deterministic and --rand apply to the key of lkgen agent, not to --agent")
+	}
+	client, err := agent.Dial(*agentSocket)
+	if err != nil {
+		log.Fatalf("lkgen agent: %v", err)
+	}
+	return client, func() {}
+}
+
+// describeSigner returns the signer of the agent client sending the
+// description, shown by lkgen agent --confirm. Other signers are returned
+// as is.
+func describeSigner(pk lk.Signer, description string) lk.Signer {
+	if client, ok := pk.(*agent.Client); ok {
+		return client.WithDescription(description)
+	}
+	return pk
+}
+
+func runAgent() {
+	pk, closeKey, err := readSigningKey(*agentKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeKey()
+
+	cfg := agent.Config{Key: pk, Timeout: *agentTimeout, AllowedUIDs: *agentAllowUID}
+	if *agentConfirm {
+		in := bufio.NewReader(os.Stdin)
+		cfg.Confirm = func(r agent.Request) bool {
+			digest := sm3.Sum(r.Message)
+			description := r.Description
+			if description == "" {
+				description = "an undescribed message"
+			}
+			fmt.Fprintf(os.Stderr, "Sign %q, %d bytes (SM3 %s…), for uid %d pid %d? [y/N] ", description, len(r.Message), hex.EncodeToString(digest[:8]), r.UID, r.PID)
+			answer, _ := in.ReadString('\n')
+			answer = strings.ToLower(strings.TrimSpace(answer))
+			return answer == "y" || answer == "yes"
+		}
+	}
+
+	a, err := agent.New(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	l, err := agent.Listen(*agentSocket)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		a.Lock()
+	}()
+
+	log.Printf("agent of key %s listening, to use it: export %s=%s", pk.GetPublicKey().Fingerprint(), agent.SocketEnvVar, *agentSocket)
+	if err := a.Serve(l); err != agent.ErrLocked {
+		log.Fatal(err)
+	}
+	log.Print("agent locked")
+}
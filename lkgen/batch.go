@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/phox/gmsm-lk"
+)
+
+// manifestHeader are the columns of the batch-sign manifest.
+var manifestHeader = []string{"row", "serial", "customer", "license", "file"}
+
+// batchRow is a row of the batch-sign input, the keys are the claim names.
+type batchRow struct {
+	n      int
+	fields map[string]interface{}
+}
+
+// readCSVRows reads rows with a header line. The features column is a ;
+// separated list and the quota.<name> columns are the quotas.
+func readCSVRows(r io.Reader) ([]batchRow, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	var rows []batchRow
+	for i, rec := range records[1:] {
+		fields := map[string]interface{}{}
+		quotas := map[string]interface{}{}
+		for j, v := range rec {
+			name := strings.ToLower(strings.TrimSpace(header[j]))
+			v = strings.TrimSpace(v)
+			switch {
+			case v == "":
+			case strings.HasPrefix(name, "quota."):
+				n, err := strconv.ParseInt(v, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("row %d: invalid quota %s=%s", i+1, name, v)
+				}
+				quotas[strings.TrimPrefix(name, "quota.")] = n
+			case name == lk.ClaimFeatures:
+				var features []interface{}
+				for _, f := range strings.Split(v, ";") {
+					if f = strings.TrimSpace(f); f != "" {
+						features = append(features, f)
+					}
+				}
+				fields[name] = features
+			default:
+				fields[name] = v
+			}
+		}
+		if len(quotas) > 0 {
+			fields[lk.ClaimQuotas] = quotas
+		}
+		rows = append(rows, batchRow{n: i + 1, fields: fields})
+	}
+	return rows, nil
+}
+
+// readJSONLRows reads one json object per line, blank lines are skipped.
+func readJSONLRows(r io.Reader) ([]batchRow, error) {
+	var rows []batchRow
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		rows = append(rows, batchRow{n: len(rows) + 1, fields: fields})
+	}
+	return rows, sc.Err()
+}
+
+// claims builds the claims of the row and returns the output file of the
+// row, if any.
+func (r batchRow) claims(now time.Time) (*lk.Claims, string, error) {
+	fields := map[string]interface{}{}
+	for k, v := range r.fields {
+		fields[k] = v
+	}
+
+	output, _ := fields["output"].(string)
+	delete(fields, "output")
+	if customer, ok := fields["customer"]; ok {
+		if _, ok := fields["subject"]; !ok {
+			fields["subject"] = customer
+		}
+		delete(fields, "customer")
+	}
+
+	for _, name := range []string{"issued_at", "not_before", "expires"} {
+		if s, ok := fields[name].(string); ok {
+			t, err := parseTime(s, now)
+			if err != nil {
+				return nil, "", err
+			}
+			fields[name] = t.Format(time.RFC3339)
+		}
+	}
+
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return nil, "", err
+	}
+	c := &lk.Claims{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, "", err
+	}
+	if c.IssuedAt.IsZero() {
+		c.IssuedAt = now
+	}
+	return c, output, c.CheckSchema()
+}
+
+// readManifest returns the rows already signed in the manifest.
+func readManifest(path string) (map[int]bool, error) {
+	done := map[int]bool{}
+	f, err := os.Open(path) // #nosec G304 -- path is given by the user
+	if errors.Is(err, os.ErrNotExist) {
+		return done, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("manifest %s: %w", path, err)
+	}
+	for i, rec := range records {
+		if i == 0 {
+			continue
+		}
+		n, err := strconv.Atoi(rec[0])
+		if err != nil {
+			return nil, fmt.Errorf("manifest %s: invalid row %q", path, rec[0])
+		}
+		done[n] = true
+	}
+	return done, nil
+}
+
+// batchSignLicenses signs the rows of the input and returns the exit code,
+// so that the deferred closes run before the process exits.
+func batchSignLicenses() int {
+	if lk.Encoding(*outFormat) == lk.EncodingRaw {
+		log.Fatal("the raw format can't be written in a manifest")
+	}
+
+	in, err := openInput(*batchSignIn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer in.Close()
+
+	var rows []batchRow
+	switch strings.ToLower(filepath.Ext(*batchSignIn)) {
+	case ".jsonl", ".ndjson":
+		rows, err = readJSONLRows(in)
+	default:
+		rows, err = readCSVRows(in)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *batchSignDryRun {
+		return batchDryRun(rows)
+	}
+
+	if *batchSignManifest == "" {
+		log.Fatal("required flag --manifest not provided")
+	}
+	pk, closeKey, err := readSigningKey(*batchSignKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeKey()
+
+	if _, err := os.Stat(*batchSignManifest); err == nil && !*batchSignResume {
+		log.Fatalf("manifest %s exists, use --resume to continue it", *batchSignManifest)
+	}
+	done, err := readManifest(*batchSignManifest)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	f, err := os.OpenFile(*batchSignManifest, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	manifest := csv.NewWriter(f)
+
+	is := openIssuance(pk)
+	defer is.close()
+	if fi, err := f.Stat(); err == nil && fi.Size() == 0 {
+		if err := manifest.Write(manifestHeader); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	signed, skipped, failed := 0, 0, 0
+	for _, row := range rows {
+		if done[row.n] {
+			skipped++
+			continue
+		}
+		if err := batchSignRow(pk, is, row, f, manifest); err != nil {
+			log.Printf("row %d: %v", row.n, err)
+			failed++
+			continue
+		}
+		signed++
+	}
+
+	log.Printf("%d signed, %d skipped, %d failed", signed, skipped, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// batchSignRow signs a row, writes its output file and appends it to the
+// manifest. The serial is reserved in the registry before anything is
+// written, so that a rejected serial leaves no license behind and the row is
+// retried on resume. The manifest is synced so that an interrupted batch can
+// be resumed, the license is appended to the ledger only once the row is
+// durably written so that a resumed batch never logs it twice.
+func batchSignRow(pk lk.Signer, is *issuance, row batchRow, f *os.File, manifest *csv.Writer) error {
+	c, output, err := row.claims(time.Now().UTC().Truncate(time.Second))
+	if err != nil {
+		return err
+	}
+
+	l, err := lk.NewClaimsLicense(pk, c)
+	if err != nil {
+		return err
+	}
+	b, err := l.Encode(lk.Encoding(*outFormat))
+	if err != nil {
+		return err
+	}
+
+	if output == "" && *batchSignOutDir != "" {
+		output = c.Serial + lk.LicenseExt
+	}
+	if output != "" {
+		if *batchSignOutDir != "" {
+			output = filepath.Join(*batchSignOutDir, filepath.Clean("/"+output))
+		} else if !filepath.IsLocal(output) {
+			return fmt.Errorf("output %q is not a relative path in the current directory", output)
+		}
+	}
+
+	if err := is.reserve(l); err != nil {
+		return err
+	}
+	if output != "" {
+		if err := writeFileSync(output, b); err != nil {
+			return err
+		}
+	}
+
+	if err := manifest.Write([]string{strconv.Itoa(row.n), c.Serial, c.Subject, string(b), output}); err != nil {
+		return err
+	}
+	manifest.Flush()
+	if err := manifest.Error(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return is.log(l)
+}
+
+// writeFileSync writes a file and syncs it to the disk.
+func writeFileSync(path string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600) // #nosec G304 -- path is checked by the caller
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// batchDryRun checks the rows, prints the claims that would be signed and
+// returns the exit code.
+func batchDryRun(rows []batchRow) int {
+	failed := 0
+	for _, row := range rows {
+		c, output, err := row.claims(time.Now().UTC().Truncate(time.Second))
+		if err != nil {
+			log.Printf("row %d: %v", row.n, err)
+			failed++
+			continue
+		}
+		b, err := json.Marshal(c)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("row %d\t%s\t%s\n", row.n, output, b)
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
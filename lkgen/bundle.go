@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/phox/gmsm-lk"
+)
+
+func signBundle() {
+	pk, closeKey, err := readSigningKey(*bundleKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeKey()
+
+	entries := make([]lk.BundleEntry, 0, len(*bundleEntries))
+	for product, path := range *bundleEntries {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		entries = append(entries, lk.BundleEntry{Product: product, Data: data})
+	}
+
+	bl, err := lk.NewBundle(pk, entries)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	writeOutput(*bundleOut, bl.Encode)
+}
+
+func inspectBundle() {
+	publicKey, err := readPublicKey(*bundleInspectPubKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	b, err := readInput(*bundleInspectIn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	bl, err := lk.ParseBundle(string(b))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *bundleInspectProduct != "" {
+		data, err := bl.VerifyProduct(publicKey, *bundleInspectProduct)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(string(data))
+		return
+	}
+
+	if ok, err := bl.Verify(publicKey); err != nil {
+		log.Fatal(err)
+	} else if !ok {
+		log.Fatal("Invalid bundle signature")
+	}
+
+	products := bl.Products()
+	sort.Strings(products)
+	for _, p := range products {
+		e, _ := bl.Entry(p)
+		fmt.Printf("%s\t%d bytes\n", p, len(e.Data))
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/phox/gmsm-lk"
+)
+
+// parseTime parses a date (2027-01-01 or RFC 3339) or a duration from now:
+// a number of days (365d), of weeks (52w) or a Go duration (36h).
+func parseTime(s string, now time.Time) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, err := strconv.Atoi(strings.TrimSuffix(s, suffix)); err == nil && strings.HasSuffix(s, suffix) {
+			return now.Add(time.Duration(n) * unit), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date or duration %q", s)
+}
+
+// claimsRequested tells if sign must build claims from the flags.
+func claimsRequested() bool {
+	return *signTemplate != "" || *signSerial != "" || *signSubject != "" || *signEmail != "" ||
+		*signProduct != "" || *signMachine != "" || *signExpires != "" || *signNotBefore != "" ||
+		len(*signFeatures) > 0 || len(*signQuotas) > 0
+}
+
+// buildClaims merges the claims flags over the template.
+func buildClaims() (*lk.Claims, error) {
+	c := &lk.Claims{}
+	if *signTemplate != "" {
+		b, err := os.ReadFile(*signTemplate)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, c); err != nil {
+			return nil, fmt.Errorf("template %s: %w", *signTemplate, err)
+		}
+	}
+	if c.Extra == nil {
+		c.Extra = map[string]interface{}{}
+	}
+
+	for _, f := range []struct {
+		flag  string
+		claim *string
+	}{
+		{*signSerial, &c.Serial},
+		{*signSubject, &c.Subject},
+		{*signProduct, &c.Product},
+		{*signMachine, &c.Machine},
+	} {
+		if f.flag != "" {
+			*f.claim = f.flag
+		}
+	}
+	if *signEmail != "" {
+		c.Extra[lk.ClaimEmail] = *signEmail
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, f := range []struct {
+		flag  string
+		claim *time.Time
+	}{
+		{*signExpires, &c.Expires},
+		{*signNotBefore, &c.NotBefore},
+	} {
+		if f.flag == "" {
+			continue
+		}
+		t, err := parseTime(f.flag, now)
+		if err != nil {
+			return nil, err
+		}
+		*f.claim = t
+	}
+
+	if len(*signFeatures) > 0 {
+		features, _ := c.Extra[lk.ClaimFeatures].([]interface{})
+		seen := map[string]bool{}
+		for _, f := range features {
+			s, ok := f.(string)
+			if !ok {
+				return nil, fmt.Errorf("the template feature %v is not a string", f)
+			}
+			seen[s] = true
+		}
+		for _, f := range *signFeatures {
+			if !seen[f] {
+				seen[f] = true
+				features = append(features, f)
+			}
+		}
+		c.Extra[lk.ClaimFeatures] = features
+	}
+
+	if len(*signQuotas) > 0 {
+		quotas, _ := c.Extra[lk.ClaimQuotas].(map[string]interface{})
+		if quotas == nil {
+			quotas = map[string]interface{}{}
+		}
+		for name, v := range *signQuotas {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid quota %s=%s", name, v)
+			}
+			quotas[name] = n
+		}
+		c.Extra[lk.ClaimQuotas] = quotas
+	}
+
+	if len(c.Extra) == 0 {
+		c.Extra = nil
+	}
+	if c.IssuedAt.IsZero() {
+		c.IssuedAt = now
+	}
+	return c, c.CheckSchema()
+}
+
+func signClaims(pk lk.Signer) {
+	c, err := buildClaims()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if c.Serial == "" {
+		if c.Serial, err = lk.NewSerial(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	l, err := lk.NewClaimsLicense(describeSigner(pk, fmt.Sprintf("license %s for %s", c.Serial, c.Subject)), c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	is := openIssuance(pk)
+	defer is.close()
+	if err := is.record(l); err != nil {
+		log.Fatal(err)
+	}
+
+	writeOutput(*signOut, l.Encode)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/base32"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/phox/gmsm-lk"
+)
+
+func genSeedFile() {
+	seed, err := lk.NewSeed()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	str := base32.StdEncoding.EncodeToString(seed) + "\n"
+	if *genOut == "" {
+		fmt.Print(str)
+		return
+	}
+	if err := os.WriteFile(*genOut, []byte(str), 0600); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// readDerivedKey derives the private key of the path from the base32 seed
+// in the file.
+func readDerivedKey(seedPath, path string) (*lk.PrivateKey, error) {
+	b, err := os.ReadFile(seedPath) // #nosec G304 -- path is given by the user
+	if err != nil {
+		return nil, err
+	}
+	seed, err := base32.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a base32 seed: %w", seedPath, err)
+	}
+	return lk.DeriveKey(seed, path)
+}
+
+func deriveKey() {
+	pk, err := readDerivedKey(*deriveSeed, *derivePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	writeOutput(*deriveOut, pk.Encode)
+}
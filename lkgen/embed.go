@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+)
+
+var embedTemplate = template.Must(template.New("embed").Parse(`// Code generated by lkgen embed. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"sync"
+
+	lk "github.com/phox/gmsm-lk"
+)
+
+// {{.Name}}KeyFingerprint is the SM3 fingerprint of the embedded public key.
+const {{.Name}}KeyFingerprint = "{{.Fingerprint}}"
+
+var (
+	{{.name}}KeyParts = [][]byte{
+{{- range .Parts}}
+		{ {{- .}} },
+{{- end}}
+	}
+	{{.name}}KeyMasks = [][]byte{
+{{- range .Masks}}
+		{ {{- .}} },
+{{- end}}
+	}
+
+	{{.name}}KeyOnce sync.Once
+	{{.name}}Key     *lk.PublicKey
+)
+
+// {{.Name}}Key returns the embedded public key. It panics if the key doesn't
+// match {{.Name}}KeyFingerprint.
+func {{.Name}}Key() *lk.PublicKey {
+	{{.name}}KeyOnce.Do(func() {
+		k, err := lk.PublicKeyFromMasked({{.name}}KeyParts, {{.name}}KeyMasks, {{.Name}}KeyFingerprint)
+		if err != nil {
+			panic(err)
+		}
+		{{.name}}Key = k
+	})
+	return {{.name}}Key
+}
+
+// Verify{{.Name}} verifies the license with the embedded public key.
+func Verify{{.Name}}(l *lk.License) error {
+	return l.VerifyErr({{.Name}}Key())
+}
+`))
+
+// byteList formats bytes as the elements of a Go byte slice literal.
+func byteList(b []byte) string {
+	s := make([]string, len(b))
+	for i, c := range b {
+		s[i] = fmt.Sprintf("0x%02x", c)
+	}
+	return strings.Join(s, ", ")
+}
+
+func embedKey() {
+	key, err := readPublicKey(*embedKeyPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pkg := *embedPackage
+	if pkg == "" {
+		// set by go generate
+		pkg = os.Getenv("GOPACKAGE")
+	}
+	if pkg == "" {
+		pkg = "main"
+	}
+	if !token.IsIdentifier(pkg) {
+		log.Fatalf("invalid package name %q", pkg)
+	}
+	if !token.IsIdentifier(*embedName) {
+		log.Fatalf("invalid name %q", *embedName)
+	}
+
+	parts, masks, err := key.Mask(*embedParts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	first, size := utf8.DecodeRuneInString(*embedName)
+	data := map[string]interface{}{
+		"Package":     pkg,
+		"Name":        string(unicode.ToUpper(first)) + (*embedName)[size:],
+		"name":        string(unicode.ToLower(first)) + (*embedName)[size:],
+		"Fingerprint": key.Fingerprint(),
+	}
+	var p, m []string
+	for i := range parts {
+		p = append(p, byteList(parts[i]))
+		m = append(m, byteList(masks[i]))
+	}
+	data["Parts"], data["Masks"] = p, m
+
+	var buf bytes.Buffer
+	if err := embedTemplate.Execute(&buf, data); err != nil {
+		log.Fatal(err)
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *embedOut != "" {
+		if err := os.WriteFile(*embedOut, src, 0644); err != nil { // #nosec G306 -- generated source, committed with the application
+			log.Fatal(err)
+		}
+	} else {
+		if _, err := os.Stdout.Write(src); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/phox/gmsm-lk"
+)
+
+// readInput returns the content of the file at path or of stdin if path is
+// empty.
+func readInput(path string) ([]byte, error) {
+	if path == "" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path) // #nosec G304 -- path is given by the user
+}
+
+// readPrivateKey reads a private key in any encoding.
+func readPrivateKey(path string) (*lk.PrivateKey, error) {
+	b, err := os.ReadFile(path) // #nosec G304 -- path is given by the user
+	if err != nil {
+		return nil, err
+	}
+	return lk.ParsePrivateKey(string(b))
+}
+
+// readSigningKey reads a private key in any encoding, with the nonces of
+// the --deterministic and --rand flags. The returned function closes the
+// --rand file.
+func readSigningKey(path string) (lk.Signer, func(), error) {
+	pk, err := readPrivateKey(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case *deterministic && *randPath != "":
+		return nil, nil, errors.New("--deterministic and --rand can't be used together")
+	case *deterministic:
+		return pk.Deterministic(), func() {}, nil
+	case *randPath != "":
+		f, err := os.Open(*randPath) // #nosec G304 -- path is given by the user
+		if err != nil {
+			return nil, nil, err
+		}
+		return pk.WithRand(bufio.NewReader(f)), func() { _ = f.Close() }, nil
+	}
+	return pk, func() {}, nil
+}
+
+// readPublicKey reads a public key, or the public key of a private key, in
+// any encoding.
+func readPublicKey(path string) (*lk.PublicKey, error) {
+	b, err := os.ReadFile(path) // #nosec G304 -- path is given by the user
+	if err != nil {
+		return nil, err
+	}
+	if key, err := lk.ParsePublicKey(string(b)); err == nil {
+		return key, nil
+	}
+	pk, err := lk.ParsePrivateKey(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("%s is neither a public nor a private key: %w", path, err)
+	}
+	return pk.GetPublicKey(), nil
+}
+
+// writeOutput writes the object in the encoding of the --format flag to the
+// file at path or to stdout if path is empty.
+func writeOutput(path string, encode func(lk.Encoding) ([]byte, error)) {
+	b, err := encode(lk.Encoding(*outFormat))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if path != "" {
+		if err := os.WriteFile(path, b, 0600); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		if _, err := os.Stdout.Write(b); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
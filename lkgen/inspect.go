@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/big"
+	"os"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/phox/gmsm-lk"
+)
+
+// readLicenseArg returns the content of the file named by arg, arg itself if
+// it is not a file, or stdin if it is empty.
+func readLicenseArg(arg string) (string, error) {
+	if arg == "" {
+		b, err := io.ReadAll(os.Stdin)
+		return string(b), err
+	}
+	if fi, err := os.Stat(arg); err == nil && !fi.IsDir() {
+		b, err := os.ReadFile(arg) // #nosec G304 -- path is given by the user
+		return string(b), err
+	}
+	return arg, nil
+}
+
+type inspection struct {
+	Format    string     `json:"format"`
+	Encoding  string     `json:"encoding"`
+	KeyID     string     `json:"kid,omitempty"`
+	R         string     `json:"r"`
+	S         string     `json:"s"`
+	Signature string     `json:"signature"`
+	Error     string     `json:"error,omitempty"`
+	Claims    *lk.Claims `json:"claims,omitempty"`
+	Data      []byte     `json:"data,omitempty"`
+	Expires   *time.Time `json:"expires,omitempty"`
+	Expired   bool       `json:"expired"`
+}
+
+// hexInt encodes a signature component checked with License.CheckSignature.
+func hexInt(n *big.Int) string {
+	b := make([]byte, 32)
+	n.FillBytes(b)
+	return hex.EncodeToString(b)
+}
+
+func inspectLicense() {
+	str, err := readLicenseArg(*inspectIn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// the signature is checked below, to print the rest of a malformed license
+	d := &lk.StrictDecoder{MaxSize: -1, SkipSignatureCheck: true}
+	l, encoding, err := d.DecodeLicense([]byte(str))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	res := inspection{
+		Format:    lk.FormatLicense,
+		Encoding:  string(encoding),
+		KeyID:     l.KeyID,
+		Signature: "unchecked",
+	}
+
+	if err := l.CheckSignature(); err != nil {
+		res.Signature = "malformed"
+		res.Error = err.Error()
+	} else {
+		res.R, res.S = hexInt(l.R), hexInt(l.S)
+		if *inspectPubKey != "" {
+			publicKey, err := readPublicKey(*inspectPubKey)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := l.VerifyErr(publicKey); err != nil {
+				res.Signature = "invalid"
+				res.Error = err.Error()
+			} else {
+				res.Signature = "valid"
+			}
+		}
+	}
+
+	if c, err := l.Claims(); err == nil {
+		res.Claims = c
+		if !c.Expires.IsZero() {
+			res.Expires = &c.Expires
+			res.Expired = !time.Now().Before(c.Expires)
+		}
+	} else {
+		res.Data = l.Data
+	}
+
+	if *inspectJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(res); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		printInspection(&res)
+	}
+
+	if res.Signature == "invalid" || res.Signature == "malformed" {
+		os.Exit(1)
+	}
+}
+
+func printInspection(res *inspection) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Format:\t%s (%s)\n", res.Format, res.Encoding)
+	if res.KeyID != "" {
+		fmt.Fprintf(w, "Key ID:\t%s\n", res.KeyID)
+	} else {
+		fmt.Fprintf(w, "Key ID:\t(none)\n")
+	}
+	switch res.Signature {
+	case "unchecked":
+		fmt.Fprintf(w, "Signature:\tUNCHECKED (no public key given)\n")
+	case "invalid":
+		fmt.Fprintf(w, "Signature:\tINVALID: %s\n", res.Error)
+	case "malformed":
+		fmt.Fprintf(w, "Signature:\tMALFORMED: %s\n", res.Error)
+	default:
+		fmt.Fprintf(w, "Signature:\tvalid\n")
+	}
+	fmt.Fprintf(w, "  r:\t%s\n", res.R)
+	fmt.Fprintf(w, "  s:\t%s\n", res.S)
+
+	switch {
+	case res.Claims == nil:
+	case res.Expires == nil:
+		fmt.Fprintf(w, "Expires:\tnever\n")
+	case res.Expired:
+		fmt.Fprintf(w, "Expires:\t%s (EXPIRED)\n", res.Expires.Format(time.RFC3339))
+	default:
+		days := int(math.Round(time.Until(*res.Expires).Hours() / 24))
+		fmt.Fprintf(w, "Expires:\t%s (in %d days)\n", res.Expires.Format(time.RFC3339), days)
+	}
+	_ = w.Flush()
+
+	if res.Claims != nil {
+		b, err := json.MarshalIndent(res.Claims, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Claims:\n%s\n", b)
+	} else if utf8.Valid(res.Data) {
+		fmt.Printf("Data (%d bytes):\n%s\n", len(res.Data), res.Data)
+	} else {
+		fmt.Printf("Data (%d bytes):\n%s", len(res.Data), hex.Dump(res.Data))
+	}
+}
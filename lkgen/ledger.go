@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/phox/gmsm-lk"
+)
+
+// issuance records the issued licenses in the ledger of the --ledger flag
+// and in the registry of the --db flag, if they are set.
+type issuance struct {
+	ledger   *lk.Ledger
+	registry *lk.Registry
+}
+
+func openIssuance(pk lk.Signer) *issuance {
+	is := &issuance{}
+	if *ledgerPath != "" {
+		lg, err := lk.OpenLedger(*ledgerPath, describeSigner(pk, "checkpoint of ledger "+*ledgerPath))
+		if err != nil {
+			log.Fatal(err)
+		}
+		lg.CheckpointEvery = *ledgerEvery
+		is.ledger = lg
+	}
+	if *registryDir != "" {
+		r, err := lk.OpenRegistry(*registryDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		is.registry = r
+	}
+	return is
+}
+
+// record registers the license first, so that a license rejected by the
+// registry is not in the ledger.
+func (is *issuance) record(l *lk.License) error {
+	if err := is.reserve(l); err != nil {
+		return err
+	}
+	return is.log(l)
+}
+
+// reserve adds the license to the registry, which rejects an invalid or an
+// already issued serial.
+func (is *issuance) reserve(l *lk.License) error {
+	if is.registry != nil {
+		if _, err := is.registry.Add(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// log appends the license to the ledger.
+func (is *issuance) log(l *lk.License) error {
+	if is.ledger != nil {
+		if _, err := is.ledger.Record(l, *ledgerOperator); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close seals the entries recorded since the last checkpoint with a new
+// checkpoint, then closes the ledger.
+func (is *issuance) close() {
+	if is.ledger != nil {
+		if h := is.ledger.Head(); h != nil && h.Kind != lk.LedgerCheckpoint {
+			if _, err := is.ledger.Checkpoint(); err != nil {
+				log.Print(err)
+			}
+		}
+		if err := is.ledger.Close(); err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+func ledgerVerify() {
+	publicKey, err := readPublicKey(*ledgerVerifyKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report, err := lk.VerifyLedgerFile(*ledgerVerifyPath, publicKey, *ledgerVerifyHead)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("%d entries, %d issued licenses, %d checkpoints\n", report.Entries, report.Issued, report.Checkpoints)
+	if report.Head != nil {
+		fmt.Printf("head: %d %s\n", report.Head.Seq, report.Head.Hash)
+	}
+	if report.LastCheckpoint != nil {
+		fmt.Printf("last checkpoint: %d at %s\n", report.LastCheckpoint.Seq, report.LastCheckpoint.Time.Format("2006-01-02T15:04:05Z07:00"))
+	}
+	if report.Unsealed > 0 {
+		fmt.Printf("%d entries after the last checkpoint, they are not signed and can be forged: run ledger checkpoint\n", report.Unsealed)
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
+
+func ledgerCheckpoint() {
+	pk, closeKey, err := readSigningKey(*ledgerCheckpointKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeKey()
+
+	lg, err := lk.OpenLedger(*ledgerCheckpointPath, pk)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer lg.Close()
+
+	e, err := lg.Checkpoint()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("checkpoint: %d %s\n", e.Seq, e.Hash)
+}
@@ -2,36 +2,157 @@ package main
 
 import (
 	"fmt"
-	"io"
 	"log"
 	"os"
 
 	"github.com/phox/gmsm-lk"
+	"github.com/phox/gmsm-lk/agent"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 var (
 	app = kingpin.New("lkgen", "A command-line utility to generate private keys and licenses.")
 
+	outFormat = app.Flag("format", "Encoding of the outputs: b32, b64, hex, pem, json or raw. The encoding of the inputs is detected.").Default("b32").Enum("b32", "b64", "hex", "pem", "json", "raw")
+
+	// Nonces of the signatures
+	deterministic = app.Flag("deterministic", "Derive the nonces of the signatures from the key and the data (RFC 6979 with HMAC-SM3), the same data give the same license.").Bool()
+	randPath      = app.Flag("rand", "File of bytes mixed in the nonces of the signatures with the key and the data (RFC 6979 section 3.6), instead of the system random source.").String()
+
+	// Record the issued licenses
+	ledgerPath     = app.Flag("ledger", "Ledger file recording the licenses issued by sign and batch-sign.").String()
+	ledgerOperator = app.Flag("operator", "Operator recorded in the ledger.").Envar("USER").String()
+	ledgerEvery    = app.Flag("checkpoint-every", "Number of ledger entries after which a signed checkpoint is appended.").Default("100").Int()
+	registryDir    = app.Flag("db", "Directory of the database of the licenses issued by sign and batch-sign.").String()
+
+	agentSocket = app.Flag("agent-socket", "Unix socket of lkgen agent.").Envar(agent.SocketEnvVar).Default(agent.DefaultSocket()).String()
+
 	// Gen a private key.
-	gen    = app.Command("gen", "Generates a base32 encoded private key.")
-	genOut = gen.Flag("output", "Output file (if not defined then stdout).").Short('o').String()
+	gen     = app.Command("gen", "Generates a base32 encoded private key.")
+	genOut  = gen.Flag("output", "Output file (if not defined then stdout).").Short('o').String()
+	genSeed = gen.Flag("seed", "Generates a base32 encoded seed to derive keys from instead.").Bool()
 
 	// Pub returns the public key.
-	pub    = app.Command("pub", "Get the public key.")
-	pubKey = pub.Arg("key", "Path to private key to use.").Required().String()
-	pubOut = pub.Flag("output", "Output file (if not defined then stdout).").Short('o').String()
+	pub     = app.Command("pub", "Get the public key.")
+	pubKey  = pub.Arg("key", "Path to private key to use.").Required().String()
+	pubOut  = pub.Flag("output", "Output file (if not defined then stdout).").Short('o').String()
+	pubPath = pub.Flag("path", "Derivation path (product/edition/year), the key is then a seed.").String()
+
+	// Derive a private key from a seed
+	derive     = app.Command("derive", "Derives a private key from a seed and a path.")
+	deriveSeed = derive.Arg("seed", "Path to the seed.").Required().String()
+	derivePath = derive.Flag("path", "Derivation path, names separated by slashes (product/edition/year).").Required().String()
+	deriveOut  = derive.Flag("output", "Output file (if not defined then stdout).").Short('o').String()
 
 	// Sign a new license
 	sign    = app.Command("sign", "Creates a license.")
-	signKey = sign.Arg("key", "Path to private key to use.").Required().String()
+	signKey = sign.Arg("key", "Path to private key to use.").String()
 	signIn  = sign.Flag("input", "Input data file (if not defined then stdin).").Short('i').String()
 	signOut = sign.Flag("output", "Output file (if not defined then stdout).").Short('o').String()
+	signDet = sign.Flag("detached", "Stream the input and output a detached signature instead of a license.").Bool()
+	signAgt = sign.Flag("agent", "Sign with the key held by lkgen agent instead of a key file.").Bool()
+
+	// Claims of a new license, used instead of the input data
+	signTemplate  = sign.Flag("template", "Claims json file the claims flags are merged over.").String()
+	signSerial    = sign.Flag("serial", "Serial of the license (default to a random serial).").String()
+	signSubject   = sign.Flag("subject", "Licensee of the license.").String()
+	signEmail     = sign.Flag("email", "Email of the licensee.").String()
+	signProduct   = sign.Flag("product", "Product of the license.").String()
+	signMachine   = sign.Flag("machine", "Machine the license is bound to.").String()
+	signExpires   = sign.Flag("expires", "Expiration date (2027-01-01) or duration from now (365d, 52w, 36h).").String()
+	signNotBefore = sign.Flag("not-before", "Start date (2027-01-01) or duration from now (365d, 52w, 36h).").String()
+	signFeatures  = sign.Flag("feature", "Enabled feature (repeatable).").Strings()
+	signQuotas    = sign.Flag("quota", "Quota as name=limit (repeatable).").StringMap()
 
 	// Verfify a license
 	verify       = app.Command("verify", "Verifies a license.")
 	verifyPubKey = verify.Arg("key", "Path to the public key to use.").Required().String()
 	verifyIn     = verify.Flag("input", "Input license file (if not defined then stdin).").Short('i').String()
+	verifySig    = verify.Flag("signature", "Detached signature file, the input is then the signed content.").String()
+
+	// Bundle several product licenses
+	bundle        = app.Command("bundle", "Creates a license bundle for several products.")
+	bundleKey     = bundle.Arg("key", "Path to private key to use.").Required().String()
+	bundleEntries = bundle.Flag("entry", "Product license data as product=file (repeatable).").Short('e').Required().StringMap()
+	bundleOut     = bundle.Flag("output", "Output file (if not defined then stdout).").Short('o').String()
+
+	// Inspect a license bundle
+	bundleInspect        = app.Command("bundle-inspect", "Verifies a license bundle and lists its products.")
+	bundleInspectPubKey  = bundleInspect.Arg("key", "Path to the public key to use.").Required().String()
+	bundleInspectIn      = bundleInspect.Flag("input", "Input bundle file (if not defined then stdin).").Short('i').String()
+	bundleInspectProduct = bundleInspect.Flag("product", "Print the data of this product instead of the product list.").Short('p').String()
+
+	// Embed a public key in a Go source file
+	embed        = app.Command("embed", "Writes a Go source file embedding the public key, for go:generate.")
+	embedKeyPath = embed.Arg("key", "Path to the public key (or the private key) to embed.").Required().String()
+	embedPackage = embed.Flag("package", "Package of the generated file (default to $GOPACKAGE or main).").String()
+	embedName    = embed.Flag("name", "Prefix of the generated names: <Name>Key, <Name>KeyFingerprint and Verify<Name>.").Default("License").String()
+	embedParts   = embed.Flag("parts", "Number of masked parts the key is split in.").Default("4").Int()
+	embedOut     = embed.Flag("output", "Output file (if not defined then stdout).").Short('o').String()
+
+	// Inspect a license without a key
+	inspect       = app.Command("inspect", "Decodes a license and shows its content, the signature is checked if a key is given.")
+	inspectIn     = inspect.Arg("license", "License string or file (if not defined then stdin), in base32, base64, hex or json.").String()
+	inspectPubKey = inspect.Flag("key", "Path to the public key to verify the license with.").Short('k').String()
+	inspectJSON   = inspect.Flag("json", "Output as json.").Bool()
+
+	// Sign licenses for the rows of a CSV or JSON Lines file
+	batchSign         = app.Command("batch-sign", "Signs a license for each row of a CSV or JSON Lines file.")
+	batchSignKey      = batchSign.Arg("key", "Path to private key to use.").Required().String()
+	batchSignIn       = batchSign.Flag("input", "Rows file, JSON Lines if its extension is .jsonl or .ndjson, CSV otherwise (if not defined then CSV from stdin).").Short('i').String()
+	batchSignManifest = batchSign.Flag("manifest", "Manifest CSV file listing the row, serial, customer and license of the signed rows.").Short('m').String()
+	batchSignOutDir   = batchSign.Flag("output-dir", "Directory of the license files of the rows, named by the output column or by the serial.").String()
+	batchSignResume   = batchSign.Flag("resume", "Continue an existing manifest, skipping the rows already signed.").Bool()
+	batchSignDryRun   = batchSign.Flag("dry-run", "Check the rows and print their claims without signing.").Bool()
+
+	// Check and seal the ledger
+	ledger               = app.Command("ledger", "Manages the issuance ledger.")
+	ledgerVerifyCmd      = ledger.Command("verify", "Verifies the hash chain and the checkpoints of a ledger.")
+	ledgerVerifyKey      = ledgerVerifyCmd.Arg("key", "Path to the public key of the checkpoints.").Required().String()
+	ledgerVerifyPath     = ledgerVerifyCmd.Arg("ledger", "Path to the ledger.").Required().String()
+	ledgerVerifyHead     = ledgerVerifyCmd.Flag("head", "Hash of a previous head of the ledger, to detect a truncation.").String()
+	ledgerCheckpointCmd  = ledger.Command("checkpoint", "Appends a signed checkpoint to a ledger.")
+	ledgerCheckpointKey  = ledgerCheckpointCmd.Arg("key", "Path to private key to use.").Required().String()
+	ledgerCheckpointPath = ledgerCheckpointCmd.Arg("ledger", "Path to the ledger.").Required().String()
+
+	// Query the database of the issued licenses
+	list         = app.Command("list", "Lists the licenses of the --db database.")
+	listCustomer = list.Flag("customer", "Only the licenses of this customer.").String()
+	listProduct  = list.Flag("product", "Only the licenses of this product.").String()
+	listCSV      = list.Flag("csv", "Export as CSV.").Bool()
+
+	show       = app.Command("show", "Prints a license of the --db database.")
+	showSerial = show.Arg("serial", "Serial of the license.").Required().String()
+
+	expiring       = app.Command("expiring", "Lists the licenses of the --db database that expire soon.")
+	expiringWithin = expiring.Flag("within", "Duration (30d) or date (2027-01-01) the licenses expire before.").Default("30d").String()
+	expiringCSV    = expiring.Flag("csv", "Export as CSV.").Bool()
+
+	// Issuance service
+	serve       = app.Command("serve-issuer", "Serves the REST API issuing, renewing and revoking the licenses of the --db database.")
+	serveKey    = serve.Arg("key", "Path to the private key.").Required().String()
+	serveTokens = serve.Flag("tokens", "JSON file listing the bearer tokens and their scope.").Required().String()
+	serveListen = serve.Flag("listen", "Address to listen on.").Default("localhost:8080").String()
+	serveRate   = serve.Flag("rate", "Requests per second allowed for each token, unlimited if 0.").Default("0").Float64()
+	serveBurst  = serve.Flag("burst", "Requests a token can send at once.").Default("0").Int()
+
+	// Signing agent
+	agentCmd      = app.Command("agent", "Holds the private key in memory and signs for sign --agent over a Unix socket.")
+	agentKey      = agentCmd.Arg("key", "Path to the private key.").Required().String()
+	agentTimeout  = agentCmd.Flag("timeout", "Locks the agent and exits after this time without signature, never if 0.").Default("15m").Duration()
+	agentConfirm  = agentCmd.Flag("confirm", "Asks for a confirmation on the terminal before each signature.").Bool()
+	agentAllowUID = agentCmd.Flag("allow-uid", "Another user allowed to use the agent (repeatable).").Ints()
+
+	// Backup of the private key in Shamir shares
+	split          = app.Command("split", "Splits the private key in Shamir shares, a threshold of them recovers it.")
+	splitKey       = split.Arg("key", "Path to the private key.").Required().String()
+	splitShares    = split.Flag("shares", "Number of shares.").Default("5").Int()
+	splitThreshold = split.Flag("threshold", "Number of shares recovering the key.").Default("3").Int()
+	splitOutDir    = split.Flag("output-dir", "Directory of the share files (if not defined then stdout).").Short('o').String()
+
+	combine       = app.Command("combine", "Recovers the private key from Shamir shares.")
+	combineShares = combine.Arg("shares", "Share files.").Required().Strings()
+	combineOut    = combine.Flag("output", "Output file (if not defined then stdout).").Short('o').String()
 )
 
 func main() {
@@ -44,132 +165,149 @@ func main() {
 	case pub.FullCommand():
 		publicKey()
 
+	case derive.FullCommand():
+		deriveKey()
+
 	// Sign a license
 	case sign.FullCommand():
 		signLicense()
 
 	case verify.FullCommand():
 		verifyLicense()
+
+	case bundle.FullCommand():
+		signBundle()
+
+	case bundleInspect.FullCommand():
+		inspectBundle()
+
+	case embed.FullCommand():
+		embedKey()
+
+	case inspect.FullCommand():
+		inspectLicense()
+
+	case batchSign.FullCommand():
+		if code := batchSignLicenses(); code != 0 {
+			os.Exit(code)
+		}
+
+	case ledgerVerifyCmd.FullCommand():
+		ledgerVerify()
+
+	case ledgerCheckpointCmd.FullCommand():
+		ledgerCheckpoint()
+
+	case list.FullCommand():
+		listLicenses()
+
+	case show.FullCommand():
+		showLicense()
+
+	case expiring.FullCommand():
+		expiringLicenses()
+
+	case serve.FullCommand():
+		serveIssuer()
+
+	case agentCmd.FullCommand():
+		runAgent()
+
+	case split.FullCommand():
+		splitKeyShares()
+
+	case combine.FullCommand():
+		combineKeyShares()
 	}
 }
 
 func publicKey() {
-	b, err := os.ReadFile(*pubKey)
-	if err != nil {
-		log.Fatal(err)
+	var pk *lk.PrivateKey
+	var err error
+	if *pubPath != "" {
+		pk, err = readDerivedKey(*pubKey, *pubPath)
+	} else {
+		pk, err = readPrivateKey(*pubKey)
 	}
-
-	pk, err := lk.PrivateKeyFromB32String(string(b[:]))
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	key := pk.GetPublicKey()
-	str := key.ToB32String()
-
-	if *pubOut != "" {
-		if err := os.WriteFile(*pubOut, []byte(str), 0600); err != nil {
-			log.Fatal(err)
-		}
-	} else {
-		if _, err := os.Stdout.WriteString(str); err != nil {
-			log.Fatal(err)
-		}
-	}
+	writeOutput(*pubOut, pk.GetPublicKey().Encode)
 }
 
 func signLicense() {
-	b, err := os.ReadFile(*signKey)
-	if err != nil {
-		log.Fatal(err)
-	}
-	pk, err := lk.PrivateKeyFromB32String(string(b[:]))
-	if err != nil {
-		log.Fatal(err)
+	pk, closeKey := readSigner()
+	defer closeKey()
+
+	if claimsRequested() {
+		if *signDet || *signIn != "" {
+			log.Fatal("the claims flags can't be used with --detached or --input, use --template")
+		}
+		signClaims(pk)
+		return
 	}
 
-	var data []byte
-	if *signIn != "" {
-		data, err = os.ReadFile(*signIn)
-	} else {
-		data, err = io.ReadAll(os.Stdin)
+	if *signDet {
+		signDetached(pk)
+		return
 	}
+
+	data, err := readInput(*signIn)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	l, err := lk.NewLicense(pk, data)
+	l, err := lk.NewLicense(describeSigner(pk, fmt.Sprintf("license of %d bytes of data", len(data))), data)
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	str, err := l.ToB32String()
-	if err != nil {
+	is := openIssuance(pk)
+	defer is.close()
+	if err := is.record(l); err != nil {
 		log.Fatal(err)
 	}
 
-	if *signOut != "" {
-		if err := os.WriteFile(*signOut, []byte(str), 0600); err != nil {
-			log.Fatal(err)
-		}
-	} else {
-		if _, err := os.Stdout.WriteString(str); err != nil {
-			log.Fatal(err)
-		}
-	}
+	writeOutput(*signOut, l.Encode)
 }
 
 func genKey() {
-	key, err := lk.NewPrivateKey()
-	if err != nil {
-		log.Fatal(err)
+	if *genSeed {
+		genSeedFile()
+		return
 	}
-	str, err := key.ToB32String()
+
+	key, err := lk.NewPrivateKey()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	if *genOut != "" {
-		if err := os.WriteFile(*genOut, []byte(str), 0600); err != nil {
-			log.Fatal(err)
-		}
-	} else {
-		if _, err := os.Stdout.WriteString(str); err != nil {
-			log.Fatal(err)
-		}
-	}
+	writeOutput(*genOut, key.Encode)
 }
 
 func verifyLicense() {
-	b, err := os.ReadFile(*verifyPubKey)
+	publicKey, err := readPublicKey(*verifyPubKey)
 	if err != nil {
-		log.Print(*verifyPubKey)
 		log.Fatal(err)
 	}
 
-	publicKey, err := lk.PublicKeyFromB32String(string(b))
-	if err != nil {
-		log.Fatal(err)
+	if *verifySig != "" {
+		verifyDetached(publicKey)
+		return
 	}
 
-	if *verifyIn != "" {
-		b, err = os.ReadFile(*verifyIn)
-	} else {
-		b, err = io.ReadAll(os.Stdin)
-	}
+	b, err := readInput(*verifyIn)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	license, err := lk.LicenseFromB32String(string(b))
+	license, err := lk.ParseLicense(string(b))
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	if ok, err := license.Verify(publicKey); err != nil {
+	if err := license.VerifyErr(publicKey); err != nil {
 		log.Fatal(err)
-	} else if !ok {
-		log.Fatal("Invalid license signature")
 	}
 	fmt.Print(string(license.Data))
 }
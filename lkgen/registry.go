@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/phox/gmsm-lk"
+)
+
+func openRegistry() *lk.Registry {
+	if *registryDir == "" {
+		log.Fatal("required flag --db not provided")
+	}
+	r, err := lk.OpenRegistry(*registryDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return r
+}
+
+// printLicenses prints the licenses as a table, or as CSV if asCSV is set.
+func printLicenses(list []*lk.IssuedLicense, asCSV bool) {
+	if asCSV {
+		if err := lk.WriteRegistryCSV(os.Stdout, list); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SERIAL\tCUSTOMER\tPRODUCT\tISSUED\tEXPIRES")
+	for _, il := range list {
+		expires := "never"
+		if !il.Expires.IsZero() {
+			expires = il.Expires.Format("2006-01-02")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", il.Serial, il.Customer, il.Product, il.IssuedAt.Format("2006-01-02"), expires)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func listLicenses() {
+	r := openRegistry()
+	printLicenses(r.Find(lk.RegistryQuery{Customer: *listCustomer, Product: *listProduct}), *listCSV)
+}
+
+func showLicense() {
+	il, err := openRegistry().Get(*showSerial)
+	if err != nil {
+		log.Fatal(err)
+	}
+	l, err := il.Decode()
+	if err != nil {
+		log.Fatal(err)
+	}
+	writeOutput("", l.Encode)
+}
+
+func expiringLicenses() {
+	now := time.Now().UTC()
+	until, err := parseTime(*expiringWithin, now)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	r := openRegistry()
+	printLicenses(r.Expiring(now, until.Sub(now)), *expiringCSV)
+}
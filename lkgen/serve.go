@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/phox/gmsm-lk"
+	"github.com/phox/gmsm-lk/issuer"
+)
+
+func serveIssuer() {
+	pk, closeKey, err := readSigningKey(*serveKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeKey()
+	tokens, err := issuer.LoadTokens(*serveTokens)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfg := issuer.Config{
+		Key:       pk,
+		Registry:  openRegistry(),
+		Tokens:    tokens,
+		RateLimit: *serveRate,
+		Burst:     *serveBurst,
+	}
+	if *ledgerPath != "" {
+		lg, err := lk.OpenLedger(*ledgerPath, pk)
+		if err != nil {
+			log.Fatal(err)
+		}
+		lg.CheckpointEvery = *ledgerEvery
+		defer lg.Close()
+		cfg.Ledger = lg
+	}
+
+	handler, err := issuer.New(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	srv := &http.Server{
+		Addr:              *serveListen,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdown); err != nil {
+			log.Print(err)
+		}
+	}()
+
+	log.Printf("listening on %s with %d tokens", *serveListen, len(tokens))
+	// the ledger is closed by the deferred call, log.Fatal would skip it
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		log.Print(err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/phox/gmsm-lk"
+)
+
+func splitKeyShares() {
+	pk, err := readPrivateKey(*splitKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	shares, err := pk.Split(*splitShares, *splitThreshold)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *splitOutDir != "" {
+		if err := os.MkdirAll(*splitOutDir, 0700); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, share := range shares {
+		text, err := formatShare(share, len(shares))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *splitOutDir == "" {
+			fmt.Println(text)
+			continue
+		}
+		path := filepath.Join(*splitOutDir, fmt.Sprintf("share-%d-of-%d.txt", share.Index, len(shares)))
+		if err := os.WriteFile(path, []byte(text), 0600); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(path)
+	}
+}
+
+// formatShare returns the share with comment lines telling the custodian
+// which share and key they hold.
+func formatShare(share *lk.KeyShare, n int) (string, error) {
+	str, err := share.ToB32String()
+	if err != nil {
+		return "", err
+	}
+	checksum, err := share.Checksum()
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# gmsm-lk key share %d of %d, %d shares recover the key\n", share.Index, n, share.Threshold)
+	fmt.Fprintf(&b, "# key:      %s\n", share.Fingerprint)
+	fmt.Fprintf(&b, "# checksum: %s\n", checksum)
+	fmt.Fprintf(&b, "%s\n", str)
+	return b.String(), nil
+}
+
+// parseShare parses a share file, ignoring the comment lines.
+func parseShare(b []byte) (*lk.KeyShare, error) {
+	var str strings.Builder
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		if line = bytes.TrimSpace(line); len(line) > 0 && line[0] != '#' {
+			str.Write(line)
+		}
+	}
+	return lk.KeyShareFromB32String(str.String())
+}
+
+func combineKeyShares() {
+	var shares []*lk.KeyShare
+	for _, path := range *combineShares {
+		b, err := os.ReadFile(path) // #nosec G304 -- path is given by the user
+		if err != nil {
+			log.Fatal(err)
+		}
+		share, err := parseShare(b)
+		if err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+		shares = append(shares, share)
+	}
+
+	pk, err := lk.CombineKeyShares(shares)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "recovered key %s\n", pk.GetPublicKey().Fingerprint())
+	writeOutput(*combineOut, pk.Encode)
+}
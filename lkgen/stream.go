@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/phox/gmsm-lk"
+)
+
+// openInput returns the file at path or stdin if path is empty.
+func openInput(path string) (io.ReadCloser, error) {
+	if path == "" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path) // #nosec G304 -- path is given by the user
+}
+
+func signDetached(pk lk.Signer) {
+	in, err := openInput(*signIn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer in.Close()
+
+	name := *signIn
+	if name == "" {
+		name = "stdin"
+	}
+	sig, err := lk.SignReader(describeSigner(pk, "detached signature of "+name), in)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	writeOutput(*signOut, sig.Encode)
+}
+
+func verifyDetached(publicKey *lk.PublicKey) {
+	b, err := os.ReadFile(*verifySig)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sig, err := lk.ParseSignature(string(b))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	in, err := openInput(*verifyIn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer in.Close()
+
+	if ok, err := sig.VerifyReader(publicKey, in); err != nil {
+		log.Fatal(err)
+	} else if !ok {
+		log.Fatal("Invalid signature")
+	}
+	fmt.Println("OK")
+}
@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package lk
+
+import "os"
+
+// lockFile does nothing, the platform has no file locks.
+func lockFile(*os.File) error {
+	return nil
+}
@@ -0,0 +1,230 @@
+package lk
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/emmansun/gmsm/sm2"
+)
+
+// The formats of the json representations.
+const (
+	FormatLicense    = "gmsm-lk/license/v1"
+	FormatPublicKey  = "gmsm-lk/public-key/v1"
+	FormatPrivateKey = "gmsm-lk/private-key/v1"
+)
+
+// ErrInvalidFormat is returned when a json document has an unexpected format
+// or algorithm.
+var ErrInvalidFormat = fmt.Errorf("%w: invalid format", ErrMalformed)
+
+type licenseJSON struct {
+	Format string `json:"format"`
+	Alg    string `json:"alg"`
+	KeyID  string `json:"kid,omitempty"`
+	Data   []byte `json:"data"`
+	R      string `json:"r"`
+	S      string `json:"s"`
+}
+
+type keyJSON struct {
+	Format string `json:"format"`
+	Alg    string `json:"alg"`
+	KeyID  string `json:"kid"`
+	X      string `json:"x,omitempty"`
+	Y      string `json:"y,omitempty"`
+	D      string `json:"d,omitempty"`
+}
+
+// intToHex encodes a 256 bits integer as 64 hexadecimal characters.
+func intToHex(n *big.Int) string {
+	if n == nil {
+		return ""
+	}
+	b := make([]byte, 32)
+	n.FillBytes(b)
+	return hex.EncodeToString(b)
+}
+
+func hexToInt(s string) (*big.Int, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 32 {
+		return nil, ErrInvalidFormat
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// MarshalJSON implements json.Marshaler. The data is base64 encoded and the
+// signature components are hexadecimal encoded, they must be in range.
+func (l *License) MarshalJSON() ([]byte, error) {
+	if err := checkSignature(l.R, l.S); err != nil {
+		return nil, err
+	}
+	return json.Marshal(licenseJSON{
+		Format: FormatLicense,
+		Alg:    AlgSM2SM3,
+		KeyID:  l.KeyID,
+		Data:   l.Data,
+		R:      intToHex(l.R),
+		S:      intToHex(l.S),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (l *License) UnmarshalJSON(b []byte) error {
+	var lj licenseJSON
+	if err := json.Unmarshal(b, &lj); err != nil {
+		return &DecodeError{Encoding: "json", Err: err}
+	}
+	if lj.Format != FormatLicense || lj.Alg != AlgSM2SM3 {
+		return ErrInvalidFormat
+	}
+
+	r, err := hexToInt(lj.R)
+	if err != nil {
+		return err
+	}
+	s, err := hexToInt(lj.S)
+	if err != nil {
+		return err
+	}
+
+	*l = License{Data: lj.Data, R: r, S: s, KeyID: lj.KeyID}
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler using the base32 encoding.
+func (l *License) MarshalText() ([]byte, error) {
+	str, err := l.ToB32String()
+	return []byte(str), err
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler using the base32
+// encoding.
+func (l *License) UnmarshalText(b []byte) error {
+	tmp, err := LicenseFromB32String(string(b))
+	if err != nil {
+		return err
+	}
+	*l = *tmp
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. The coordinates are hexadecimal
+// encoded.
+func (k *PublicKey) MarshalJSON() ([]byte, error) {
+	return json.Marshal(keyJSON{
+		Format: FormatPublicKey,
+		Alg:    "SM2",
+		KeyID:  k.Fingerprint(),
+		X:      intToHex(k.X),
+		Y:      intToHex(k.Y),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The point is checked to be on
+// the curve and to match the key id.
+func (k *PublicKey) UnmarshalJSON(b []byte) error {
+	var kj keyJSON
+	if err := json.Unmarshal(b, &kj); err != nil {
+		return &DecodeError{Encoding: "json", Err: err}
+	}
+	if kj.Format != FormatPublicKey || kj.Alg != "SM2" {
+		return ErrInvalidFormat
+	}
+
+	x, err := hexToInt(kj.X)
+	if err != nil {
+		return err
+	}
+	y, err := hexToInt(kj.Y)
+	if err != nil {
+		return err
+	}
+	if !sm2.P256().IsOnCurve(x, y) {
+		return &DecodeError{Encoding: "json", Err: ErrInvalidPublicKey}
+	}
+
+	tmp := PublicKey{X: x, Y: y}
+	if kj.KeyID != "" && kj.KeyID != tmp.Fingerprint() {
+		return &DecodeError{Encoding: "json", Err: ErrInvalidPublicKey}
+	}
+	*k = tmp
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler using the base32 encoding.
+func (k *PublicKey) MarshalText() ([]byte, error) {
+	return []byte(k.ToB32String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler using the base32
+// encoding.
+func (k *PublicKey) UnmarshalText(b []byte) error {
+	tmp, err := PublicKeyFromB32String(string(b))
+	if err != nil {
+		return err
+	}
+	*k = *tmp
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. The private scalar is hexadecimal
+// encoded, the output must be kept as secret as the key itself.
+func (k *PrivateKey) MarshalJSON() ([]byte, error) {
+	return json.Marshal(keyJSON{
+		Format: FormatPrivateKey,
+		Alg:    "SM2",
+		KeyID:  k.GetPublicKey().Fingerprint(),
+		D:      intToHex(k.key.D),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (k *PrivateKey) UnmarshalJSON(b []byte) error {
+	var kj keyJSON
+	if err := json.Unmarshal(b, &kj); err != nil {
+		return &DecodeError{Encoding: "json", Err: err}
+	}
+	if kj.Format != FormatPrivateKey || kj.Alg != "SM2" {
+		return ErrInvalidFormat
+	}
+
+	d, err := hexToInt(kj.D)
+	if err != nil {
+		return err
+	}
+	if err := checkScalar(d); err != nil {
+		return err
+	}
+	tmp, err := sm2.NewPrivateKeyFromInt(d)
+	if err != nil {
+		return &DecodeError{Encoding: "json", Err: err}
+	}
+
+	pk := PrivateKey{key: tmp}
+	if kj.KeyID != "" && kj.KeyID != pk.GetPublicKey().Fingerprint() {
+		return &DecodeError{Encoding: "json", Err: ErrInvalidPublicKey}
+	}
+	*k = pk
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler using the base32 encoding.
+func (k *PrivateKey) MarshalText() ([]byte, error) {
+	str, err := k.ToB32String()
+	return []byte(str), err
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler using the base32
+// encoding.
+func (k *PrivateKey) UnmarshalText(b []byte) error {
+	tmp, err := PrivateKeyFromB32String(string(b))
+	if err != nil {
+		return err
+	}
+	*k = *tmp
+	return nil
+}
@@ -0,0 +1,112 @@
+package lk_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+
+	lk "github.com/phox/gmsm-lk"
+)
+
+func (s *Suite) TestMarshal() {
+	privateKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+	publicKey := privateKey.GetPublicKey()
+
+	license, err := lk.NewLicense(privateKey, s.RandomBytes(100))
+	s.Require().NoError(err)
+
+	s.Run("should round trip a license in json", func() {
+		b, err := json.Marshal(license)
+		s.Require().NoError(err)
+
+		m := map[string]interface{}{}
+		s.Require().NoError(json.Unmarshal(b, &m))
+		s.Require().Equal(lk.FormatLicense, m["format"])
+		s.Require().Equal("SM2SM3", m["alg"])
+		s.Require().Equal(publicKey.Fingerprint(), m["kid"])
+		s.Require().Len(m["r"], 64)
+
+		l2 := &lk.License{}
+		s.Require().NoError(json.Unmarshal(b, l2))
+		s.Require().Equal(license, l2)
+
+		b2, err := json.Marshal(l2)
+		s.Require().NoError(err)
+		s.Require().Equal(b, b2)
+
+		s.Require().Error(json.Unmarshal([]byte(`{"format":"other"}`), l2))
+	})
+
+	s.Run("should not marshal an invalid signature", func() {
+		for _, l := range []*lk.License{
+			{Data: license.Data, R: new(big.Int).Lsh(big.NewInt(1), 300), S: license.S},
+			{Data: license.Data, R: license.R},
+		} {
+			_, err := json.Marshal(l)
+			s.Require().ErrorIs(err, lk.ErrMalformed)
+		}
+	})
+
+	s.Run("should round trip keys in json", func() {
+		b, err := json.Marshal(publicKey)
+		s.Require().NoError(err)
+		pub := &lk.PublicKey{}
+		s.Require().NoError(json.Unmarshal(b, pub))
+		s.Require().Equal(publicKey, pub)
+
+		b, err = json.Marshal(privateKey)
+		s.Require().NoError(err)
+		priv := &lk.PrivateKey{}
+		s.Require().NoError(json.Unmarshal(b, priv))
+		s.Require().Equal(privateKey, priv)
+
+		other, err := lk.NewPrivateKey()
+		s.Require().NoError(err)
+		m := map[string]interface{}{}
+		s.Require().NoError(json.Unmarshal(b, &m))
+		m["kid"] = other.GetPublicKey().Fingerprint()
+		b, err = json.Marshal(m)
+		s.Require().NoError(err)
+		s.Require().ErrorIs(json.Unmarshal(b, priv), lk.ErrInvalidPublicKey)
+		s.Require().ErrorIs(json.Unmarshal(b, priv), lk.ErrMalformed)
+	})
+
+	s.Run("should report malformed json as malformed", func() {
+		zero := `{"format":"gmsm-lk/private-key/v1","alg":"SM2","d":"` + strings.Repeat("00", 32) + `"}`
+		offCurve := `{"format":"gmsm-lk/public-key/v1","alg":"SM2","x":"` + strings.Repeat("01", 32) + `","y":"` + strings.Repeat("01", 32) + `"}`
+		for name, err := range map[string]error{
+			"license syntax":     (&lk.License{}).UnmarshalJSON([]byte(`{`)),
+			"public key syntax":  (&lk.PublicKey{}).UnmarshalJSON([]byte(`{`)),
+			"private key syntax": (&lk.PrivateKey{}).UnmarshalJSON([]byte(`{`)),
+			"zero scalar":        (&lk.PrivateKey{}).UnmarshalJSON([]byte(zero)),
+			"off curve":          (&lk.PublicKey{}).UnmarshalJSON([]byte(offCurve)),
+		} {
+			s.Require().ErrorIs(err, lk.ErrMalformed, name)
+		}
+	})
+
+	s.Run("should use text marshalers", func() {
+		b, err := license.MarshalText()
+		s.Require().NoError(err)
+		str, err := license.ToB32String()
+		s.Require().NoError(err)
+		s.Require().Equal(str, string(b))
+
+		l2 := &lk.License{}
+		s.Require().NoError(l2.UnmarshalText(b))
+		s.Require().Equal(license, l2)
+
+		b, err = publicKey.MarshalText()
+		s.Require().NoError(err)
+		pub := &lk.PublicKey{}
+		s.Require().NoError(pub.UnmarshalText(b))
+		s.Require().Equal(publicKey, pub)
+
+		b, err = privateKey.MarshalText()
+		s.Require().NoError(err)
+		priv := &lk.PrivateKey{}
+		s.Require().NoError(priv.UnmarshalText(b))
+		s.Require().Equal(privateKey, priv)
+	})
+}
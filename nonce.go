@@ -0,0 +1,152 @@
+package lk
+
+import (
+	"crypto/hmac"
+	"errors"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/sm3"
+)
+
+// randSigner signs with nonces derived from the key, the message and bytes
+// read from a reader.
+type randSigner struct {
+	*PrivateKey
+	mu   sync.Mutex
+	rand io.Reader
+}
+
+func (k *randSigner) SignMessage(msg []byte) (r, s *big.Int, err error) {
+	extra := make([]byte, sm3.Size)
+	k.mu.Lock()
+	_, err = io.ReadFull(k.rand, extra)
+	k.mu.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+	return signRFC6979(k.PrivateKey, msg, extra)
+}
+
+// WithRand returns a Signer with the key mixing the bytes read from the
+// reader in the nonces, instead of using crypto/rand. The nonces are derived
+// from the key, the message and 32 bytes read for each signature as in RFC
+// 6979 section 3.6, so reading the same bytes for different messages doesn't
+// reveal the key. The Signer is safe for concurrent use, the reads are
+// serialized. Use Deterministic for reproducible signatures.
+func (k *PrivateKey) WithRand(r io.Reader) Signer {
+	return &randSigner{PrivateKey: k, rand: r}
+}
+
+// deterministicSigner signs with the nonces of RFC 6979 using HMAC-SM3.
+type deterministicSigner struct {
+	*PrivateKey
+}
+
+// Deterministic returns a Signer with the key computing the nonces from the
+// key and the message as in RFC 6979, with HMAC-SM3. The same data signed
+// twice give the same license.
+func (k *PrivateKey) Deterministic() Signer {
+	return &deterministicSigner{PrivateKey: k}
+}
+
+func (k *deterministicSigner) SignMessage(msg []byte) (r, s *big.Int, err error) {
+	return signRFC6979(k.PrivateKey, msg, nil)
+}
+
+// signRFC6979 signs with the nonces of RFC 6979, extra is the additional
+// data of section 3.6. The signature itself is computed by gmsm in constant
+// time.
+//
+// This relies on how the signer of gmsm reads its nonces, which is not part
+// of its API: go.mod pins the version checked by the known answer tests, and
+// each signature is checked to use the last nonce read, so that a version
+// reading them otherwise fails instead of signing with another nonce.
+func signRFC6979(k *PrivateKey, msg, extra []byte) (r, s *big.Int, err error) {
+	digest, err := sm2.CalculateSM2Hash(&k.key.PublicKey, msg, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonces := &nonceReader{g: newRFC6979(k.key.D, digest, extra)}
+	r, s, err = sm2.Sign(nonces, &k.key.PrivateKey, digest)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !nonces.signed(digest, r) {
+		return nil, nil, errors.New("lk: the signature doesn't use the RFC 6979 nonce, check the gmsm version")
+	}
+	return r, s, nil
+}
+
+// nonceReader gives the nonce candidates to the signer of gmsm, which reads
+// each of them as a scalar of the group order size and rejects the ones out
+// of [1, n-1] as RFC 6979 does. The reads of other sizes, such as the byte
+// gmsm may read to keep callers from relying on the nonces, are zeros and
+// don't consume a candidate.
+type nonceReader struct {
+	g    *rfc6979
+	last []byte
+}
+
+func (r *nonceReader) Read(b []byte) (int, error) {
+	if len(b) != sm3.Size {
+		clear(b)
+		return len(b), nil
+	}
+	r.last = r.g.next()
+	return copy(b, r.last), nil
+}
+
+// signed tells if r is the r of an SM2 signature of the digest with the
+// last nonce read: r = e + x(k·G) mod n. The scalar multiplication of gmsm
+// is constant time.
+func (r *nonceReader) signed(digest []byte, sigR *big.Int) bool {
+	if r.last == nil {
+		return false
+	}
+	c := sm2.P256()
+	x, _ := c.ScalarBaseMult(r.last)
+	want := new(big.Int).Add(new(big.Int).SetBytes(digest), x)
+	return want.Mod(want, c.Params().N).Cmp(sigR) == 0
+}
+
+// rfc6979 generates the nonce candidates of RFC 6979 section 3.2 with
+// HMAC-SM3, for a 256 bits group order.
+type rfc6979 struct {
+	k, v []byte
+}
+
+func newRFC6979(d *big.Int, digest, extra []byte) *rfc6979 {
+	x := d.FillBytes(make([]byte, 32))
+	h := new(big.Int).Mod(new(big.Int).SetBytes(digest), sm2.P256().Params().N).FillBytes(make([]byte, 32))
+
+	g := &rfc6979{k: make([]byte, sm3.Size), v: make([]byte, sm3.Size)}
+	for i := range g.v {
+		g.v[i] = 1
+	}
+	g.k = g.mac(g.v, []byte{0}, x, h, extra)
+	g.v = g.mac(g.v)
+	g.k = g.mac(g.v, []byte{1}, x, h, extra)
+	g.v = g.mac(g.v)
+	return g
+}
+
+func (g *rfc6979) mac(data ...[]byte) []byte {
+	m := hmac.New(sm3.New, g.k)
+	for _, b := range data {
+		m.Write(b)
+	}
+	return m.Sum(nil)
+}
+
+// next returns the next candidate, the state is updated for the next call
+// whether the candidate is in range or not.
+func (g *rfc6979) next() []byte {
+	g.v = g.mac(g.v)
+	t := g.v
+	g.k = g.mac(g.v, []byte{0})
+	g.v = g.mac(g.v)
+	return t
+}
@@ -0,0 +1,144 @@
+package lk_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/emmansun/gmsm/sm2"
+	lk "github.com/phox/gmsm-lk"
+)
+
+type countingReader struct {
+	n int
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	r.n += len(b)
+	return rand.Read(b)
+}
+
+func (s *Suite) TestDeterministic() {
+	privateKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+	signer := privateKey.Deterministic()
+	c := &lk.Claims{Serial: "serial-1", Subject: "ACME"}
+
+	s.Run("should sign the same claims the same way", func() {
+		a, err := lk.NewClaimsLicense(signer, c)
+		s.Require().NoError(err)
+		b, err := lk.NewClaimsLicense(privateKey.Deterministic(), c)
+		s.Require().NoError(err)
+
+		s.Require().NoError(a.VerifyErr(privateKey.GetPublicKey()))
+		strA, err := a.ToB32String()
+		s.Require().NoError(err)
+		strB, err := b.ToB32String()
+		s.Require().NoError(err)
+		s.Require().Equal(strA, strB)
+
+		random, err := lk.NewClaimsLicense(privateKey, c)
+		s.Require().NoError(err)
+		s.Require().NotEqual(a.S, random.S)
+	})
+
+	s.Run("should verify", func() {
+		seen := map[string]bool{}
+		for i := 0; i < 50; i++ {
+			l, err := lk.NewLicense(signer, []byte{byte(i)})
+			s.Require().NoError(err)
+			s.Require().NoError(l.VerifyErr(privateKey.GetPublicKey()))
+			seen[l.R.String()] = true
+		}
+		s.Require().Len(seen, 50)
+	})
+
+	s.Run("should match the known answers", func() {
+		// cross-checked with a math/big implementation of the SM2
+		// signature and RFC 6979 with HMAC-SM3
+		k, err := lk.DeriveKey(make([]byte, 32), "m/0")
+		s.Require().NoError(err)
+		for msg, want := range map[string][2]string{
+			"sample": {
+				"2ef32b892cf60bb923c0d39c138b357c5da137270b503f3b5925d3efc79c110e",
+				"f7c029674674d4d33a381cbedfffea454fa242b2a24cc899c92f4c0303e04a2d",
+			},
+			"test": {
+				"351ea557d1ce6fccf42d7d8868fac73b5208d6d05bf67c9e1de94adeedb711f9",
+				"2c1803b2ed78c725bdfc4274dc03eee57605b11f3342b89509e69ccb3611bdcb",
+			},
+		} {
+			r, sig, err := k.Deterministic().SignMessage([]byte(msg))
+			s.Require().NoError(err)
+			s.Require().Equal(want[0], hex.EncodeToString(r.FillBytes(make([]byte, 32))), msg)
+			s.Require().Equal(want[1], hex.EncodeToString(sig.FillBytes(make([]byte, 32))), msg)
+		}
+	})
+
+	s.Run("should depend on the key", func() {
+		other, err := lk.NewPrivateKey()
+		s.Require().NoError(err)
+		a, err := lk.NewLicense(signer, []byte("data"))
+		s.Require().NoError(err)
+		b, err := lk.NewLicense(other.Deterministic(), []byte("data"))
+		s.Require().NoError(err)
+		s.Require().NotEqual(a.R, b.R)
+	})
+}
+
+func (s *Suite) TestWithRand() {
+	privateKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+
+	r := &countingReader{}
+	l, err := lk.NewLicense(privateKey.WithRand(r), []byte("data"))
+	s.Require().NoError(err)
+	s.Require().NoError(l.VerifyErr(privateKey.GetPublicKey()))
+	s.Require().GreaterOrEqual(r.n, 32)
+
+	_, err = lk.NewLicense(privateKey.WithRand(bytes.NewReader(nil)), []byte("data"))
+	s.Require().True(errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF), err)
+
+	s.Run("should not reuse nonces with the same bytes", func() {
+		pub, err := sm2.NewPublicKey(privateKey.GetPublicKey().ToBytes())
+		s.Require().NoError(err)
+		// x1 = r - e mod n is the x coordinate of the nonce point
+		x1 := func(msg []byte, r *big.Int) *big.Int {
+			e, err := sm2.CalculateSM2Hash(pub, msg, nil)
+			s.Require().NoError(err)
+			x := new(big.Int).Sub(r, new(big.Int).SetBytes(e))
+			return x.Mod(x, sm2.P256().Params().N)
+		}
+
+		signer := privateKey.WithRand(bytes.NewReader(make([]byte, 64)))
+		ra, sa, err := signer.SignMessage([]byte("a"))
+		s.Require().NoError(err)
+		rb, _, err := signer.SignMessage([]byte("b"))
+		s.Require().NoError(err)
+		s.Require().NotEqual(x1([]byte("a"), ra), x1([]byte("b"), rb))
+
+		// the same bytes and message give the same signature
+		rc, sc, err := privateKey.WithRand(bytes.NewReader(make([]byte, 32))).SignMessage([]byte("a"))
+		s.Require().NoError(err)
+		s.Require().Equal(ra, rc)
+		s.Require().Equal(sa, sc)
+	})
+
+	s.Run("should be safe for concurrent use", func() {
+		signer := privateKey.WithRand(&countingReader{})
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _, err := signer.SignMessage([]byte("data"))
+				s.NoError(err)
+			}()
+		}
+		wg.Wait()
+	})
+}
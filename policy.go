@@ -0,0 +1,255 @@
+package lk
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule is a named check of a Policy. A rule is either an expression over the
+// claims (see expr.go for the language), or a composition of rules that must
+// All pass or of which Any must pass.
+type Rule struct {
+	Name    string `json:"name" yaml:"name"`
+	Expr    string `json:"expr,omitempty" yaml:"expr,omitempty"`
+	All     []Rule `json:"all,omitempty" yaml:"all,omitempty"`
+	Any     []Rule `json:"any,omitempty" yaml:"any,omitempty"`
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+
+	expr exprNode
+}
+
+// Policy is a set of rules validating the claims of a license.
+type Policy struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// NewPolicy returns a policy with the rules, the expressions are compiled.
+func NewPolicy(rules ...Rule) (*Policy, error) {
+	p := &Policy{Rules: rules}
+	return p, p.compile()
+}
+
+// ParsePolicyJSON returns a policy from its json representation.
+func ParsePolicyJSON(b []byte) (*Policy, error) {
+	p := &Policy{}
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, &DecodeError{Encoding: "json", Err: err}
+	}
+	return p, p.compile()
+}
+
+// ParsePolicyYAML returns a policy from its yaml representation.
+func ParsePolicyYAML(b []byte) (*Policy, error) {
+	p := &Policy{}
+	if err := yaml.Unmarshal(b, p); err != nil {
+		return nil, &DecodeError{Encoding: "yaml", Err: err}
+	}
+	return p, p.compile()
+}
+
+// LoadPolicy reads a policy file, the format is chosen by the extension of
+// the file: .yaml or .yml for yaml, json otherwise.
+func LoadPolicy(path string) (*Policy, error) {
+	b, err := os.ReadFile(path) // #nosec G304 -- path is chosen by the application
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ParsePolicyYAML(b)
+	}
+	return ParsePolicyJSON(b)
+}
+
+func (p *Policy) compile() error {
+	for i := range p.Rules {
+		if err := p.Rules[i].compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Rule) compile() error {
+	n := 0
+	if r.Expr != "" {
+		n++
+	}
+	if len(r.All) > 0 {
+		n++
+	}
+	if len(r.Any) > 0 {
+		n++
+	}
+	if n != 1 {
+		return fmt.Errorf("%w: rule %q must have one of expr, all or any", ErrInvalidExpr, r.Name)
+	}
+
+	if r.Expr != "" {
+		e, err := parseExpr(r.Expr)
+		if err != nil {
+			return fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+		r.expr = e
+	}
+	for i := range r.All {
+		if err := r.All[i].compile(); err != nil {
+			return err
+		}
+	}
+	for i := range r.Any {
+		if err := r.Any[i].compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RuleResult is the result of a rule of a Policy.
+type RuleResult struct {
+	Rule    string `json:"rule"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+	// Failed lists the failed sub rules of a composed rule.
+	Failed []string `json:"failed,omitempty"`
+	// Err is set if the rule couldn't be evaluated, the rule then fails.
+	Err error `json:"-"`
+}
+
+// Report is the result of the evaluation of a Policy.
+type Report struct {
+	Results []RuleResult `json:"results"`
+}
+
+// OK tells if all the rules passed.
+func (r *Report) OK() bool {
+	return len(r.Failures()) == 0
+}
+
+// Failures returns the results of the failed rules.
+func (r *Report) Failures() []RuleResult {
+	var res []RuleResult
+	for _, rr := range r.Results {
+		if !rr.Passed {
+			res = append(res, rr)
+		}
+	}
+	return res
+}
+
+// Err returns nil if all the rules passed, otherwise an error listing the
+// failed rules.
+func (r *Report) Err() error {
+	f := r.Failures()
+	if len(f) == 0 {
+		return nil
+	}
+	names := make([]string, len(f))
+	for i, rr := range f {
+		names[i] = rr.Rule
+	}
+	return fmt.Errorf("lk: policy failed: %s", strings.Join(names, ", "))
+}
+
+// claimsVars returns the variables of the expressions: the claims by their
+// json names, the extra claims, env and now.
+func claimsVars(c *Claims, env map[string]interface{}, now time.Time) map[string]interface{} {
+	vars := map[string]interface{}{}
+	for k, v := range c.Extra {
+		vars[k] = v
+	}
+	for k, v := range map[string]interface{}{
+		"serial":  c.Serial,
+		"subject": c.Subject,
+		"product": c.Product,
+		"machine": c.Machine,
+	} {
+		vars[k] = v
+	}
+	for k, t := range map[string]time.Time{
+		"issued_at":  c.IssuedAt,
+		"not_before": c.NotBefore,
+		"expires":    c.Expires,
+	} {
+		if t.IsZero() {
+			vars[k] = nil
+		} else {
+			vars[k] = t
+		}
+	}
+	if env == nil {
+		env = map[string]interface{}{}
+	}
+	vars["env"] = env
+	vars["now"] = now
+	return vars
+}
+
+// Evaluate evaluates the policy on the claims. env holds the values of the
+// running application (current usage, version...) available as env.<name>
+// in the expressions.
+func (p *Policy) Evaluate(c *Claims, env map[string]interface{}) *Report {
+	vars := claimsVars(c, env, time.Now())
+	report := &Report{}
+	for i := range p.Rules {
+		report.Results = append(report.Results, p.Rules[i].evaluate(vars))
+	}
+	return report
+}
+
+func (r *Rule) evaluate(vars map[string]interface{}) RuleResult {
+	res := RuleResult{Rule: r.Name, Message: r.Message}
+
+	switch {
+	case r.expr != nil:
+		v, err := r.expr.eval(vars)
+		if err != nil {
+			res.Err = err
+		} else if b, ok := v.(bool); !ok {
+			res.Err = fmt.Errorf("%w: rule %q is not a boolean", ErrInvalidExpr, r.Name)
+		} else {
+			res.Passed = b
+		}
+	case len(r.All) > 0:
+		for i := range r.All {
+			if sub := r.All[i].evaluate(vars); !sub.Passed {
+				res.Failed = append(res.Failed, sub.Rule)
+			}
+		}
+		res.Passed = len(res.Failed) == 0
+	case len(r.Any) > 0:
+		for i := range r.Any {
+			sub := r.Any[i].evaluate(vars)
+			if sub.Passed {
+				res.Passed = true
+				res.Failed = nil
+				break
+			}
+			res.Failed = append(res.Failed, sub.Rule)
+		}
+	default:
+		res.Err = fmt.Errorf("%w: rule %q is not compiled", ErrInvalidExpr, r.Name)
+	}
+
+	if res.Err != nil && res.Message == "" {
+		res.Message = res.Err.Error()
+	}
+	return res
+}
+
+// Validate evaluates the policy on the claims of the license. The signature
+// is not verified, use VerifyClaims first.
+func (l *License) Validate(p *Policy, env map[string]interface{}) (*Report, error) {
+	c, err := l.Claims()
+	if err != nil {
+		return nil, err
+	}
+	return p.Evaluate(c, env), nil
+}
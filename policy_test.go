@@ -0,0 +1,112 @@
+package lk_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	lk "github.com/phox/gmsm-lk"
+)
+
+func (s *Suite) TestPolicy() {
+	privateKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+
+	license, err := lk.NewClaimsLicense(privateKey, &lk.Claims{
+		Product: "editor",
+		Expires: time.Now().Add(90 * 24 * time.Hour),
+		Extra: map[string]interface{}{
+			"seats":       float64(10),
+			"region":      "eu",
+			"min_version": "2.1",
+			"features":    []interface{}{"export", "sync"},
+		},
+	})
+	s.Require().NoError(err)
+
+	s.Run("should evaluate a policy built in code", func() {
+		p, err := lk.NewPolicy(
+			lk.Rule{Name: "product", Expr: `product in ["editor", "suite"]`},
+			lk.Rule{Name: "seats", Expr: "env.users <= seats"},
+			lk.Rule{Name: "version", Expr: `vercmp(env.version, min_version) >= 0`},
+			lk.Rule{Name: "expiry", Expr: "expires > now + days(30)"},
+			lk.Rule{Name: "region", Any: []lk.Rule{
+				{Name: "eu", Expr: `region == "eu"`},
+				{Name: "us", Expr: `region == "us"`},
+			}},
+			lk.Rule{Name: "features", All: []lk.Rule{
+				{Name: "export", Expr: `"export" in features`},
+				{Name: "print", Expr: `contains(features, "print")`},
+			}},
+		)
+		s.Require().NoError(err)
+
+		report, err := license.Validate(p, map[string]interface{}{"users": 5, "version": "2.10"})
+		s.Require().NoError(err)
+		s.Require().False(report.OK())
+
+		failures := report.Failures()
+		s.Require().Len(failures, 1)
+		s.Require().Equal("features", failures[0].Rule)
+		s.Require().Equal([]string{"print"}, failures[0].Failed)
+		s.Require().EqualError(report.Err(), "lk: policy failed: features")
+
+		report, err = license.Validate(p, map[string]interface{}{"users": 11, "version": "2.0.9"})
+		s.Require().NoError(err)
+		s.Require().Len(report.Failures(), 3)
+	})
+
+	s.Run("should load a policy file", func() {
+		dir := s.T().TempDir()
+		yml := filepath.Join(dir, "policy.yaml")
+		s.Require().NoError(os.WriteFile(yml, []byte(`
+rules:
+  - name: product
+    expr: product == "editor"
+    message: this license is not for the editor
+  - name: seats
+    expr: env.users * 2 <= seats && !(region == "cn")
+`), 0600))
+
+		p, err := lk.LoadPolicy(yml)
+		s.Require().NoError(err)
+		report, err := license.Validate(p, map[string]interface{}{"users": 5})
+		s.Require().NoError(err)
+		s.Require().True(report.OK())
+
+		js := filepath.Join(dir, "policy.json")
+		s.Require().NoError(os.WriteFile(js, []byte(`{"rules":[{"name":"seats","expr":"seats > 10"}]}`), 0600))
+		p, err = lk.LoadPolicy(js)
+		s.Require().NoError(err)
+		report, err = license.Validate(p, nil)
+		s.Require().NoError(err)
+		s.Require().False(report.OK())
+	})
+
+	s.Run("should report invalid expressions", func() {
+		deep := strings.Repeat("(", 1000) + "1" + strings.Repeat(")", 1000)
+		for _, expr := range []string{`product ==`, `"open`, `(seats`, `unknown(1)`, `seats $ 1`, deep} {
+			_, err := lk.NewPolicy(lk.Rule{Name: "bad", Expr: expr})
+			s.Require().ErrorIs(err, lk.ErrInvalidExpr, expr)
+		}
+
+		_, err := lk.NewPolicy(lk.Rule{Name: "empty"})
+		s.Require().ErrorIs(err, lk.ErrInvalidExpr)
+
+		p, err := lk.NewPolicy(lk.Rule{Name: "type", Expr: `seats + "a"`})
+		s.Require().NoError(err)
+		report, err := license.Validate(p, nil)
+		s.Require().NoError(err)
+		s.Require().False(report.OK())
+		s.Require().ErrorIs(report.Results[0].Err, lk.ErrInvalidExpr)
+	})
+
+	s.Run("should not compare uncomparable values", func() {
+		p, err := lk.NewPolicy(lk.Rule{Name: "env", Expr: `env.ids == env.ids || env.ids in [1]`})
+		s.Require().NoError(err)
+		report, err := license.Validate(p, map[string]interface{}{"ids": []int{1}})
+		s.Require().NoError(err)
+		s.Require().False(report.OK())
+	})
+}
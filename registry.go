@@ -0,0 +1,301 @@
+package lk
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSerialExists is returned when a license is added to a Registry that
+// already holds its serial.
+var ErrSerialExists = errors.New("lk: serial already registered")
+
+// IssuedLicense is a license recorded in a Registry.
+type IssuedLicense struct {
+	Serial   string    `json:"serial"`
+	Customer string    `json:"customer,omitempty"`
+	Product  string    `json:"product,omitempty"`
+	IssuedAt time.Time `json:"issued_at"`
+	Expires  time.Time `json:"expires"`
+	// License is the base32 encoded license.
+	License string `json:"license"`
+	// RevokedAt is the revocation time, zero if the license is not
+	// revoked.
+	RevokedAt    time.Time `json:"revoked_at"`
+	RevokeReason string    `json:"revoke_reason,omitempty"`
+}
+
+// Decode returns the license.
+func (il *IssuedLicense) Decode() (*License, error) {
+	return LicenseFromB32String(il.License)
+}
+
+// Registry is a database of the issued licenses stored as one json file per
+// serial in a directory. The licenses are indexed in memory by serial,
+// customer, product and expiration date. It is safe for concurrent use, the
+// licenses returned are copies.
+type Registry struct {
+	dir string
+
+	mu         sync.RWMutex
+	bySerial   map[string]*IssuedLicense
+	byCustomer map[string][]*IssuedLicense
+	byProduct  map[string][]*IssuedLicense
+	// byExpiry holds the licenses that expire, sorted by expiration date.
+	byExpiry []*IssuedLicense
+}
+
+// OpenRegistry opens the registry in the directory, it is created if it
+// doesn't exist.
+func OpenRegistry(dir string) (*Registry, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	r := &Registry{
+		dir:        dir,
+		bySerial:   map[string]*IssuedLicense{},
+		byCustomer: map[string][]*IssuedLicense{},
+		byProduct:  map[string][]*IssuedLicense{},
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		b, err := os.ReadFile(path) // #nosec G304 -- path is in the registry directory
+		if err != nil {
+			return nil, err
+		}
+		il := &IssuedLicense{}
+		if err := json.Unmarshal(b, il); err != nil {
+			return nil, &DecodeError{Encoding: "json", Err: fmt.Errorf("%s: %w", path, err)}
+		}
+		r.index(il)
+	}
+	return r, nil
+}
+
+func (r *Registry) index(il *IssuedLicense) {
+	r.bySerial[il.Serial] = il
+	customer := strings.ToLower(il.Customer)
+	r.byCustomer[customer] = append(r.byCustomer[customer], il)
+	r.byProduct[il.Product] = append(r.byProduct[il.Product], il)
+	if !il.Expires.IsZero() {
+		i := sort.Search(len(r.byExpiry), func(i int) bool { return r.byExpiry[i].Expires.After(il.Expires) })
+		r.byExpiry = append(r.byExpiry, nil)
+		copy(r.byExpiry[i+1:], r.byExpiry[i:])
+		r.byExpiry[i] = il
+	}
+}
+
+// Add records a license, its claims must have a serial that is not in the
+// registry yet. The signature is not verified.
+func (r *Registry) Add(l *License) (*IssuedLicense, error) {
+	c, err := l.Claims()
+	if err != nil {
+		return nil, err
+	}
+	if !validName.MatchString(c.Serial) {
+		return nil, fmt.Errorf("%w: serial %q", ErrInvalidName, c.Serial)
+	}
+	str, err := l.ToB32String()
+	if err != nil {
+		return nil, err
+	}
+
+	il := &IssuedLicense{
+		Serial:   c.Serial,
+		Customer: c.Subject,
+		Product:  c.Product,
+		IssuedAt: c.IssuedAt,
+		Expires:  c.Expires,
+		License:  str,
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.bySerial[il.Serial]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrSerialExists, il.Serial)
+	}
+	if err := r.write(il); err != nil {
+		return nil, err
+	}
+	r.index(il)
+	cp := *il
+	return &cp, nil
+}
+
+func (r *Registry) write(il *IssuedLicense) error {
+	b, err := json.MarshalIndent(il, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(r.dir, il.Serial+".json"), b)
+}
+
+// Revoke marks the license with the serial as revoked. It returns ErrRevoked
+// if it already was.
+func (r *Registry) Revoke(serial, reason string, at time.Time) (*IssuedLicense, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	il, ok := r.bySerial[serial]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrLicenseNotFound, serial)
+	}
+	if !il.RevokedAt.IsZero() {
+		return nil, fmt.Errorf("%w: %s", ErrRevoked, serial)
+	}
+
+	cp := *il
+	cp.RevokedAt, cp.RevokeReason = at.UTC(), reason
+	if err := r.write(&cp); err != nil {
+		return nil, err
+	}
+	*il = cp
+	return &cp, nil
+}
+
+// RevocationList returns the serials revoked in the registry.
+func (r *Registry) RevocationList() *RevocationList {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rl := NewRevocationList()
+	for serial, il := range r.bySerial {
+		if !il.RevokedAt.IsZero() {
+			rl.Revoke(serial)
+		}
+	}
+	return rl
+}
+
+// Get returns the license with the serial.
+func (r *Registry) Get(serial string) (*IssuedLicense, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if il, ok := r.bySerial[serial]; ok {
+		cp := *il
+		return &cp, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrLicenseNotFound, serial)
+}
+
+// RegistryQuery selects licenses of a Registry, the zero fields match any
+// license.
+type RegistryQuery struct {
+	// Customer matches the subject of the claims, ignoring the case.
+	Customer string
+	Product  string
+	// ExpiresAfter and ExpiresBefore select the licenses expiring in
+	// [ExpiresAfter, ExpiresBefore). Licenses that never expire don't
+	// match when one of them is set.
+	ExpiresAfter  time.Time
+	ExpiresBefore time.Time
+}
+
+func (q *RegistryQuery) match(il *IssuedLicense) bool {
+	if q.Customer != "" && !strings.EqualFold(q.Customer, il.Customer) {
+		return false
+	}
+	if q.Product != "" && q.Product != il.Product {
+		return false
+	}
+	if !q.ExpiresAfter.IsZero() || !q.ExpiresBefore.IsZero() {
+		if il.Expires.IsZero() || il.Expires.Before(q.ExpiresAfter) {
+			return false
+		}
+		if !q.ExpiresBefore.IsZero() && !il.Expires.Before(q.ExpiresBefore) {
+			return false
+		}
+	}
+	return true
+}
+
+// Find returns the licenses matching the query sorted by expiration date,
+// the licenses that never expire last.
+func (r *Registry) Find(q RegistryQuery) []*IssuedLicense {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// start from the most selective index
+	var candidates []*IssuedLicense
+	switch {
+	case q.Customer != "":
+		candidates = r.byCustomer[strings.ToLower(q.Customer)]
+	case q.Product != "":
+		candidates = r.byProduct[q.Product]
+	case !q.ExpiresAfter.IsZero() || !q.ExpiresBefore.IsZero():
+		i := sort.Search(len(r.byExpiry), func(i int) bool { return !r.byExpiry[i].Expires.Before(q.ExpiresAfter) })
+		candidates = r.byExpiry[i:]
+	default:
+		for _, il := range r.bySerial {
+			candidates = append(candidates, il)
+		}
+	}
+
+	var res []*IssuedLicense
+	for _, il := range candidates {
+		if q.match(il) {
+			cp := *il
+			res = append(res, &cp)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		switch {
+		case a.Expires.IsZero() != b.Expires.IsZero():
+			return b.Expires.IsZero()
+		case !a.Expires.Equal(b.Expires):
+			return a.Expires.Before(b.Expires)
+		}
+		return a.Serial < b.Serial
+	})
+	return res
+}
+
+// Expiring returns the licenses that are valid at now and expire within the
+// duration. The licenses revoked at now are skipped.
+func (r *Registry) Expiring(now time.Time, within time.Duration) []*IssuedLicense {
+	var res []*IssuedLicense
+	for _, il := range r.Find(RegistryQuery{ExpiresAfter: now.Add(time.Nanosecond), ExpiresBefore: now.Add(within)}) {
+		if il.RevokedAt.IsZero() || il.RevokedAt.After(now) {
+			res = append(res, il)
+		}
+	}
+	return res
+}
+
+// WriteRegistryCSV writes the licenses as CSV with a header line.
+func WriteRegistryCSV(w io.Writer, licenses []*IssuedLicense) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"serial", "customer", "product", "issued_at", "expires", "license"}); err != nil {
+		return err
+	}
+	for _, il := range licenses {
+		if err := cw.Write([]string{
+			il.Serial, il.Customer, il.Product, formatTime(il.IssuedAt), formatTime(il.Expires), il.License,
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatTime formats a time as RFC 3339, the zero time as an empty string.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
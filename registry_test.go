@@ -0,0 +1,103 @@
+package lk_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"time"
+
+	lk "github.com/phox/gmsm-lk"
+)
+
+func (s *Suite) TestRegistry() {
+	privateKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	dir := s.T().TempDir()
+	registry, err := lk.OpenRegistry(dir)
+	s.Require().NoError(err)
+
+	for _, c := range []*lk.Claims{
+		{Serial: "a", Subject: "ACME", Product: "editor", Expires: now.Add(10 * 24 * time.Hour)},
+		{Serial: "b", Subject: "ACME", Product: "suite", Expires: now.Add(60 * 24 * time.Hour)},
+		{Serial: "c", Subject: "Globex", Product: "editor", Expires: now.Add(-24 * time.Hour)},
+		{Serial: "d", Subject: "Globex", Product: "editor"},
+	} {
+		l, err := lk.NewClaimsLicense(privateKey, c)
+		s.Require().NoError(err)
+		_, err = registry.Add(l)
+		s.Require().NoError(err)
+	}
+
+	serials := func(list []*lk.IssuedLicense) []string {
+		var res []string
+		for _, il := range list {
+			res = append(res, il.Serial)
+		}
+		return res
+	}
+
+	s.Run("should get a license", func() {
+		il, err := registry.Get("b")
+		s.Require().NoError(err)
+		s.Require().Equal("suite", il.Product)
+
+		l, err := il.Decode()
+		s.Require().NoError(err)
+		s.Require().NoError(l.VerifyErr(privateKey.GetPublicKey()))
+
+		_, err = registry.Get("x")
+		s.Require().ErrorIs(err, lk.ErrLicenseNotFound)
+	})
+
+	s.Run("should reject a known serial", func() {
+		l, err := lk.NewClaimsLicense(privateKey, &lk.Claims{Serial: "a"})
+		s.Require().NoError(err)
+		_, err = registry.Add(l)
+		s.Require().ErrorIs(err, lk.ErrSerialExists)
+	})
+
+	s.Run("should find licenses", func() {
+		s.Require().Equal([]string{"c", "a", "b", "d"}, serials(registry.Find(lk.RegistryQuery{})))
+		s.Require().Equal([]string{"a", "b"}, serials(registry.Find(lk.RegistryQuery{Customer: "acme"})))
+		s.Require().Equal([]string{"c", "a", "d"}, serials(registry.Find(lk.RegistryQuery{Product: "editor"})))
+		s.Require().Equal([]string{"a"}, serials(registry.Find(lk.RegistryQuery{Customer: "ACME", Product: "editor"})))
+		s.Require().Equal([]string{"a"}, serials(registry.Expiring(now, 30*24*time.Hour)))
+		s.Require().Equal([]string{"a", "b"}, serials(registry.Expiring(now, 90*24*time.Hour)))
+	})
+
+	s.Run("should reopen the registry", func() {
+		reopened, err := lk.OpenRegistry(dir)
+		s.Require().NoError(err)
+		s.Require().Equal(serials(registry.Find(lk.RegistryQuery{})), serials(reopened.Find(lk.RegistryQuery{})))
+	})
+
+	s.Run("should export as csv", func() {
+		var buf bytes.Buffer
+		s.Require().NoError(lk.WriteRegistryCSV(&buf, registry.Find(lk.RegistryQuery{Customer: "globex"})))
+
+		records, err := csv.NewReader(&buf).ReadAll()
+		s.Require().NoError(err)
+		s.Require().Len(records, 3)
+		s.Require().Equal([]string{"serial", "customer", "product", "issued_at", "expires", "license"}, records[0])
+		s.Require().Equal("c", records[1][0])
+		s.Require().Equal("", records[2][4])
+	})
+	s.Run("should revoke a license", func() {
+		il, err := registry.Revoke("b", "refund", now)
+		s.Require().NoError(err)
+		s.Require().Equal("refund", il.RevokeReason)
+
+		_, err = registry.Revoke("b", "", now)
+		s.Require().ErrorIs(err, lk.ErrRevoked)
+
+		reopened, err := lk.OpenRegistry(dir)
+		s.Require().NoError(err)
+		il, err = reopened.Get("b")
+		s.Require().NoError(err)
+		s.Require().True(il.RevokedAt.Equal(now))
+		s.Require().ErrorIs(reopened.RevocationList().Check(&lk.Claims{Serial: "b"}), lk.ErrRevoked)
+		s.Require().NoError(reopened.RevocationList().Check(&lk.Claims{Serial: "a"}))
+		s.Require().Equal([]string{"a"}, serials(reopened.Expiring(now, 90*24*time.Hour)))
+	})
+}
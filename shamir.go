@@ -0,0 +1,220 @@
+package lk
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/sm3"
+)
+
+var (
+	// ErrInvalidShare is returned when a key share is malformed, has a bad
+	// checksum or doesn't belong with the other shares.
+	ErrInvalidShare = fmt.Errorf("%w: invalid key share", ErrMalformed)
+	// ErrNotEnoughShares is returned when fewer shares than the threshold
+	// are combined.
+	ErrNotEnoughShares = errors.New("lk: not enough key shares")
+)
+
+const (
+	keyShareVersion = 1
+	// keyShareSize is the size of an encoded share: version, threshold,
+	// index, split id, key fingerprint, value and checksum.
+	keyShareSize = 3 + 4 + 32 + 32 + 4
+	// keyShareGroup is the number of characters between the dashes of the
+	// string form.
+	keyShareGroup = 6
+)
+
+var keyShareEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// KeyShare is a Shamir share of the scalar of a private key over the SM2
+// group order. Threshold shares of the same split recover the key.
+type KeyShare struct {
+	// Index is the x coordinate of the share, from 1 to the number of
+	// shares.
+	Index     int
+	Threshold int
+	// SplitID identifies the split, the shares of different splits of the
+	// same key can't be combined.
+	SplitID [4]byte
+	// Fingerprint is the fingerprint of the public key.
+	Fingerprint string
+	Value       *big.Int
+}
+
+// Split splits the private key in n shares, any threshold of them recover
+// the key and fewer tell nothing about it.
+func (k *PrivateKey) Split(n, threshold int) ([]*KeyShare, error) {
+	if threshold < 2 || threshold > n || n > 255 {
+		return nil, fmt.Errorf("lk: invalid split %d of %d shares, 2 <= threshold <= shares <= 255", threshold, n)
+	}
+	order := sm2.P256().Params().N
+
+	// f(x) = d + a1 x + ... + a(t-1) x^(t-1)
+	coefs := make([]*big.Int, threshold)
+	coefs[0] = new(big.Int).Set(k.key.D)
+	for i := 1; i < threshold; i++ {
+		c, err := rand.Int(rand.Reader, order)
+		if err != nil {
+			return nil, err
+		}
+		coefs[i] = c
+	}
+	var id [4]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+
+	fingerprint := k.GetPublicKey().Fingerprint()
+	shares := make([]*KeyShare, n)
+	for i := range shares {
+		x := big.NewInt(int64(i + 1))
+		y := new(big.Int)
+		for j := threshold - 1; j >= 0; j-- {
+			y.Mul(y, x).Add(y, coefs[j]).Mod(y, order)
+		}
+		shares[i] = &KeyShare{Index: i + 1, Threshold: threshold, SplitID: id, Fingerprint: fingerprint, Value: y}
+	}
+	return shares, nil
+}
+
+// CombineKeyShares recovers the private key from threshold shares of the
+// same split. The recovered key must match the fingerprint of the shares.
+func CombineKeyShares(shares []*KeyShare) (*PrivateKey, error) {
+	if len(shares) == 0 {
+		return nil, ErrNotEnoughShares
+	}
+	first := shares[0]
+	seen := map[int]bool{}
+	for _, s := range shares {
+		switch {
+		case s.Threshold != first.Threshold || s.SplitID != first.SplitID || s.Fingerprint != first.Fingerprint:
+			return nil, fmt.Errorf("%w: share %d is not from the split of share %d", ErrInvalidShare, s.Index, first.Index)
+		case s.Index < 1 || s.Value == nil:
+			return nil, ErrInvalidShare
+		case seen[s.Index]:
+			return nil, fmt.Errorf("%w: share %d given twice", ErrInvalidShare, s.Index)
+		}
+		seen[s.Index] = true
+	}
+	if len(shares) < first.Threshold {
+		return nil, fmt.Errorf("%w: %d of %d", ErrNotEnoughShares, len(shares), first.Threshold)
+	}
+	shares = shares[:first.Threshold]
+
+	// Lagrange interpolation at 0
+	order := sm2.P256().Params().N
+	d := new(big.Int)
+	for i, si := range shares {
+		num, den := big.NewInt(1), big.NewInt(1)
+		for j, sj := range shares {
+			if i == j {
+				continue
+			}
+			num.Mul(num, big.NewInt(int64(-sj.Index))).Mod(num, order)
+			den.Mul(den, big.NewInt(int64(si.Index-sj.Index))).Mod(den, order)
+		}
+		term := new(big.Int).ModInverse(den, order)
+		term.Mul(term, num).Mul(term, si.Value).Mod(term, order)
+		d.Add(d, term).Mod(d, order)
+	}
+
+	if err := checkScalar(d); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidShare, err)
+	}
+	key, err := sm2.NewPrivateKeyFromInt(d)
+	if err != nil {
+		return nil, err
+	}
+	pk := &PrivateKey{key: key}
+	if fp := pk.GetPublicKey().Fingerprint(); fp != first.Fingerprint {
+		return nil, &KeyError{KeyID: fp, Expected: first.Fingerprint}
+	}
+	return pk, nil
+}
+
+func (s *KeyShare) toBytes() ([]byte, error) {
+	fp, err := hex.DecodeString(s.Fingerprint)
+	if err != nil || len(fp) != 32 {
+		return nil, fmt.Errorf("%w: fingerprint", ErrInvalidShare)
+	}
+	if s.Index < 1 || s.Index > 255 || s.Threshold < 2 || s.Threshold > 255 || s.Value == nil || s.Value.Sign() < 0 || s.Value.BitLen() > 256 {
+		return nil, ErrInvalidShare
+	}
+
+	b := make([]byte, 0, keyShareSize)
+	b = append(b, keyShareVersion, byte(s.Threshold), byte(s.Index))
+	b = append(b, s.SplitID[:]...)
+	b = append(b, fp...)
+	b = append(b, s.Value.FillBytes(make([]byte, 32))...)
+	sum := sm3.Sum(b)
+	return append(b, sum[:4]...), nil
+}
+
+// Checksum returns the checksum of the share in hex. It is part of the
+// encoded share, custodians compare it with the one printed by the split.
+func (s *KeyShare) Checksum() (string, error) {
+	b, err := s.toBytes()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[keyShareSize-4:]), nil
+}
+
+// ToB32String encodes the share in base32, in groups of six characters
+// separated by dashes to be copied by hand.
+func (s *KeyShare) ToB32String() (string, error) {
+	b, err := s.toBytes()
+	if err != nil {
+		return "", err
+	}
+	str := keyShareEncoding.EncodeToString(b)
+	var groups []string
+	for len(str) > keyShareGroup {
+		groups = append(groups, str[:keyShareGroup])
+		str = str[keyShareGroup:]
+	}
+	return strings.Join(append(groups, str), "-"), nil
+}
+
+// KeyShareFromB32String decodes a share, ignoring the case, the dashes and
+// the spaces. The checksum is verified.
+func KeyShareFromB32String(str string) (*KeyShare, error) {
+	str = strings.ToUpper(strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, str))
+	b, err := keyShareEncoding.DecodeString(str)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidShare, err)
+	}
+	if len(b) != keyShareSize || b[0] != keyShareVersion {
+		return nil, ErrInvalidShare
+	}
+	sum := sm3.Sum(b[:keyShareSize-4])
+	if !bytes.Equal(sum[:4], b[keyShareSize-4:]) {
+		return nil, fmt.Errorf("%w: bad checksum", ErrInvalidShare)
+	}
+
+	s := &KeyShare{
+		Threshold:   int(b[1]),
+		Index:       int(b[2]),
+		Fingerprint: hex.EncodeToString(b[7:39]),
+		Value:       new(big.Int).SetBytes(b[39:71]),
+	}
+	copy(s.SplitID[:], b[3:7])
+	if s.Threshold < 2 || s.Index < 1 {
+		return nil, ErrInvalidShare
+	}
+	return s, nil
+}
@@ -0,0 +1,85 @@
+package lk_test
+
+import (
+	"strings"
+
+	lk "github.com/phox/gmsm-lk"
+)
+
+func (s *Suite) TestKeyShares() {
+	privateKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+	expected, err := privateKey.ToBytes()
+	s.Require().NoError(err)
+
+	shares, err := privateKey.Split(5, 3)
+	s.Require().NoError(err)
+	s.Require().Len(shares, 5)
+
+	s.Run("should recover the key from any threshold shares", func() {
+		for _, idx := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+			var subset []*lk.KeyShare
+			for _, i := range idx {
+				subset = append(subset, shares[i])
+			}
+			k, err := lk.CombineKeyShares(subset)
+			s.Require().NoError(err)
+			b, err := k.ToBytes()
+			s.Require().NoError(err)
+			s.Require().Equal(expected, b)
+		}
+	})
+
+	s.Run("should require the threshold", func() {
+		_, err := lk.CombineKeyShares(shares[:2])
+		s.Require().ErrorIs(err, lk.ErrNotEnoughShares)
+		_, err = lk.CombineKeyShares([]*lk.KeyShare{shares[0], shares[1], shares[0]})
+		s.Require().ErrorIs(err, lk.ErrInvalidShare)
+	})
+
+	s.Run("should round trip the string form", func() {
+		str, err := shares[1].ToB32String()
+		s.Require().NoError(err)
+		s.Require().Contains(str, "-")
+		checksum, err := shares[1].Checksum()
+		s.Require().NoError(err)
+		s.Require().Len(checksum, 8)
+
+		share, err := lk.KeyShareFromB32String(" " + strings.ToLower(str) + "\n")
+		s.Require().NoError(err)
+		s.Require().Equal(shares[1], share)
+	})
+
+	s.Run("should detect typos", func() {
+		str, err := shares[0].ToB32String()
+		s.Require().NoError(err)
+		typo := []byte(str)
+		if typo[10] == 'A' {
+			typo[10] = 'B'
+		} else {
+			typo[10] = 'A'
+		}
+		_, err = lk.KeyShareFromB32String(string(typo))
+		s.Require().ErrorIs(err, lk.ErrInvalidShare)
+		s.Require().ErrorIs(err, lk.ErrMalformed)
+	})
+
+	s.Run("should not mix splits", func() {
+		other, err := privateKey.Split(5, 3)
+		s.Require().NoError(err)
+		_, err = lk.CombineKeyShares([]*lk.KeyShare{shares[0], shares[1], other[2]})
+		s.Require().ErrorIs(err, lk.ErrInvalidShare)
+
+		forged := *shares[2]
+		forged.Value = other[2].Value
+		_, err = lk.CombineKeyShares([]*lk.KeyShare{shares[0], shares[1], &forged})
+		s.Require().ErrorIs(err, lk.ErrUnknownKey)
+	})
+
+	s.Run("should reject invalid splits", func() {
+		for _, c := range [][2]int{{3, 1}, {2, 3}, {300, 3}} {
+			_, err := privateKey.Split(c[0], c[1])
+			s.Require().Error(err)
+		}
+	})
+}
@@ -0,0 +1,252 @@
+package lk
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LicenseExt is the extension of the license files of a Store.
+const LicenseExt = ".lic"
+
+var (
+	// ErrLicenseNotFound is returned when a license is not in a Store.
+	ErrLicenseNotFound = errors.New("lk: license not found")
+
+	// ErrInvalidName is returned when a license name can't be used as a
+	// file name.
+	ErrInvalidName = errors.New("lk: invalid license name")
+)
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Store keeps the licenses of an application as base32 files in standard
+// locations. By order of precedence:
+//
+//   - the directory of the <APP>_LICENSE_DIR environment variable, if it is
+//     set no other location is used,
+//   - the user configuration directory ($XDG_CONFIG_HOME/<app>),
+//   - the system configuration directory (/etc/<app>),
+//   - the directory of the executable.
+type Store struct {
+	app  string
+	dirs []string
+}
+
+// NewStore returns the Store of an application.
+func NewStore(app string) *Store {
+	return &Store{app: app}
+}
+
+// NewStoreAt returns a Store using only the given directories.
+func NewStoreAt(dirs ...string) *Store {
+	return &Store{dirs: dirs}
+}
+
+// EnvVar returns the name of the environment variable overriding the
+// locations of the store.
+func (s *Store) EnvVar() string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, s.app)
+	return name + "_LICENSE_DIR"
+}
+
+// Locations returns the directories of the store by order of precedence.
+func (s *Store) Locations() []string {
+	if s.dirs != nil {
+		return s.dirs
+	}
+	if dir := os.Getenv(s.EnvVar()); dir != "" {
+		return []string{dir}
+	}
+
+	var dirs []string
+	if dir, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, filepath.Join(dir, s.app))
+	}
+	dirs = append(dirs, filepath.Join("/etc", s.app))
+	if exe, err := os.Executable(); err == nil {
+		dirs = append(dirs, filepath.Dir(exe))
+	}
+	return dirs
+}
+
+// licenseName returns the name of a license: its serial or its digest.
+func licenseName(l *License) (string, error) {
+	if c, err := l.Claims(); err == nil && c.Serial != "" && validName.MatchString(c.Serial) {
+		return c.Serial, nil
+	}
+	h, err := l.Digest()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h[:8]), nil
+}
+
+// Install writes the license in the first location that can be written. The
+// name is the serial of the license if empty. The file is written
+// atomically and its path is returned.
+func (s *Store) Install(name string, l *License) (string, error) {
+	if name == "" {
+		var err error
+		if name, err = licenseName(l); err != nil {
+			return "", err
+		}
+	}
+	if !validName.MatchString(name) {
+		return "", ErrInvalidName
+	}
+
+	str, err := l.ToB32String()
+	if err != nil {
+		return "", err
+	}
+
+	var firstErr error
+	for _, dir := range s.Locations() {
+		path := filepath.Join(dir, name+LicenseExt)
+		err := writeFileAtomic(path, []byte(str))
+		if err == nil {
+			return path, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		firstErr = ErrLicenseNotFound
+	}
+	return "", firstErr
+}
+
+// writeFileAtomic writes a file through a temporary file renamed once it is
+// complete.
+func writeFileAtomic(path string, b []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Path returns the path of the license with the given name, from the first
+// location holding it.
+func (s *Store) Path(name string) (string, error) {
+	if !validName.MatchString(name) {
+		return "", ErrInvalidName
+	}
+	for _, dir := range s.Locations() {
+		path := filepath.Join(dir, name+LicenseExt)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", ErrLicenseNotFound
+}
+
+// Load returns the license with the given name, in any encoding. The
+// license is not verified.
+func (s *Store) Load(name string) (*License, error) {
+	path, err := s.Path(name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path) // #nosec G304 -- path is built from the store locations
+	if err != nil {
+		return nil, err
+	}
+	l, _, err := (&StrictDecoder{}).DecodeLicense(b)
+	return l, err
+}
+
+// StoredLicense is a license file of a Store.
+type StoredLicense struct {
+	Name string
+	Path string
+}
+
+// List returns the licenses of the store. When a name is in several
+// locations only the one with the highest precedence is returned.
+func (s *Store) List() ([]StoredLicense, error) {
+	seen := map[string]bool{}
+	var res []StoredLicense
+
+	for _, dir := range s.Locations() {
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, os.ErrPermission) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			name := strings.TrimSuffix(e.Name(), LicenseExt)
+			if e.IsDir() || !strings.HasSuffix(e.Name(), LicenseExt) || seen[name] {
+				continue
+			}
+			seen[name] = true
+			res = append(res, StoredLicense{Name: name, Path: filepath.Join(dir, e.Name())})
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+// Remove deletes the license with the given name from every location.
+func (s *Store) Remove(name string) error {
+	if !validName.MatchString(name) {
+		return ErrInvalidName
+	}
+
+	found := false
+	for _, dir := range s.Locations() {
+		path := filepath.Join(dir, name+LicenseExt)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		found = true
+	}
+	if !found {
+		return ErrLicenseNotFound
+	}
+	return nil
+}
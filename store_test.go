@@ -0,0 +1,77 @@
+package lk_test
+
+import (
+	"os"
+	"path/filepath"
+
+	lk "github.com/phox/gmsm-lk"
+)
+
+func (s *Suite) TestStore() {
+	privateKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+	license, err := lk.NewClaimsLicense(privateKey, &lk.Claims{Serial: "serial-1"})
+	s.Require().NoError(err)
+
+	s.Run("should use the environment override", func() {
+		dir := s.T().TempDir()
+		store := lk.NewStore("my-app")
+		s.Require().Equal("MY_APP_LICENSE_DIR", store.EnvVar())
+
+		s.T().Setenv(store.EnvVar(), dir)
+		s.Require().Equal([]string{dir}, store.Locations())
+	})
+
+	s.Run("should install, load, list and remove", func() {
+		readOnly := filepath.Join(s.T().TempDir(), "file")
+		s.Require().NoError(os.WriteFile(readOnly, nil, 0600))
+		dir := s.T().TempDir()
+
+		// the first location can't be created, the license goes in the next one
+		store := lk.NewStoreAt(filepath.Join(readOnly, "sub"), dir)
+
+		path, err := store.Install("", license)
+		s.Require().NoError(err)
+		s.Require().Equal(filepath.Join(dir, "serial-1.lic"), path)
+
+		_, err = store.Install("main", license)
+		s.Require().NoError(err)
+
+		l, err := store.Load("serial-1")
+		s.Require().NoError(err)
+		s.Require().NoError(l.VerifyErr(privateKey.GetPublicKey()))
+
+		list, err := store.List()
+		s.Require().NoError(err)
+		s.Require().Equal([]lk.StoredLicense{
+			{Name: "main", Path: filepath.Join(dir, "main.lic")},
+			{Name: "serial-1", Path: path},
+		}, list)
+
+		s.Require().NoError(store.Remove("main"))
+		s.Require().ErrorIs(store.Remove("main"), lk.ErrLicenseNotFound)
+		_, err = store.Load("main")
+		s.Require().ErrorIs(err, lk.ErrLicenseNotFound)
+
+		_, err = store.Install("../escape", license)
+		s.Require().ErrorIs(err, lk.ErrInvalidName)
+
+		entries, err := os.ReadDir(dir)
+		s.Require().NoError(err)
+		s.Require().Len(entries, 1)
+	})
+
+	s.Run("should load a license in any encoding", func() {
+		dir := s.T().TempDir()
+		store := lk.NewStoreAt(dir)
+		for _, enc := range []lk.Encoding{lk.EncodingHex, lk.EncodingB64, lk.EncodingPEM, lk.EncodingJSON} {
+			b, err := license.Encode(enc)
+			s.Require().NoError(err)
+			s.Require().NoError(os.WriteFile(filepath.Join(dir, string(enc)+lk.LicenseExt), b, 0600))
+
+			l, err := store.Load(string(enc))
+			s.Require().NoError(err, enc)
+			s.Require().NoError(l.VerifyErr(privateKey.GetPublicKey()))
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package lk
+
+import (
+	"io"
+	"math/big"
+
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/sm3"
+)
+
+// Signature is a detached SM2 signature of a content. It is computed the
+// same way as the signature of a License, so the signature of a content is
+// also valid for a License holding that content as data.
+type Signature struct {
+	R     *big.Int
+	S     *big.Int
+	KeyID string
+}
+
+// hashReader computes the SM3 digest of r without loading it in memory.
+func hashReader(r io.Reader) ([]byte, error) {
+	h := sm3.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// SignReader reads r until EOF and returns its detached signature.
+func SignReader(k Signer, r io.Reader) (*Signature, error) {
+	h, err := hashReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sr, ss, err := k.SignMessage(h)
+	if err != nil {
+		return nil, err
+	}
+	return &Signature{R: sr, S: ss, KeyID: k.GetPublicKey().Fingerprint()}, nil
+}
+
+// VerifyReader reads r until EOF and verifies the signature with the public
+// key.
+func (sig *Signature) VerifyReader(k *PublicKey, r io.Reader) (bool, error) {
+	if err := checkSignature(sig.R, sig.S); err != nil {
+		return false, err
+	}
+
+	h, err := hashReader(r)
+	if err != nil {
+		return false, err
+	}
+
+	pub, err := sm2.NewPublicKey(k.ToBytes())
+	if err != nil {
+		return false, err
+	}
+	return sm2.VerifyWithSM2(pub, nil, h, sig.R, sig.S), nil
+}
+
+// ToBytes transforms the signature to a []byte.
+func (sig *Signature) ToBytes() ([]byte, error) {
+	return toBytes(sig)
+}
+
+// ToB64String transforms the signature to a base64 string.
+func (sig *Signature) ToB64String() (string, error) {
+	return toB64String(sig)
+}
+
+// ToB32String transforms the signature to a base32 string.
+func (sig *Signature) ToB32String() (string, error) {
+	return toB32String(sig)
+}
+
+// ToHexString transforms the signature to a hexadecimal string.
+func (sig *Signature) ToHexString() (string, error) {
+	return toHexString(sig)
+}
+
+// SignatureFromBytes returns a Signature from a []byte.
+func SignatureFromBytes(b []byte) (*Signature, error) {
+	sig := &Signature{}
+	return sig, fromBytes(sig, b)
+}
+
+// SignatureFromB64String returns a Signature from a base64 encoded string.
+func SignatureFromB64String(str string) (*Signature, error) {
+	sig := &Signature{}
+	return sig, fromB64String(sig, str)
+}
+
+// SignatureFromB32String returns a Signature from a base32 encoded string.
+func SignatureFromB32String(str string) (*Signature, error) {
+	sig := &Signature{}
+	return sig, fromB32String(sig, str)
+}
+
+// SignatureFromHexString returns a Signature from a hexadecimal encoded
+// string.
+func SignatureFromHexString(str string) (*Signature, error) {
+	sig := &Signature{}
+	return sig, fromHexString(sig, str)
+}
@@ -0,0 +1,50 @@
+package lk_test
+
+import (
+	"bytes"
+	"io"
+
+	lk "github.com/phox/gmsm-lk"
+)
+
+func (s *Suite) TestStream() {
+	privateKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+	wrongKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+
+	content := s.RandomBytes(1 << 20)
+
+	sig, err := lk.SignReader(privateKey, bytes.NewReader(content))
+	s.Require().NoError(err)
+	s.Require().Equal(privateKey.GetPublicKey().Fingerprint(), sig.KeyID)
+
+	s.Run("should verify a stream", func() {
+		ok, err := sig.VerifyReader(privateKey.GetPublicKey(), bytes.NewReader(content))
+		s.Require().NoError(err)
+		s.Require().True(ok)
+
+		ok, err = sig.VerifyReader(wrongKey.GetPublicKey(), bytes.NewReader(content))
+		s.Require().NoError(err)
+		s.Require().False(ok)
+
+		ok, err = sig.VerifyReader(privateKey.GetPublicKey(), io.LimitReader(bytes.NewReader(content), 100))
+		s.Require().NoError(err)
+		s.Require().False(ok)
+	})
+
+	s.Run("should match the license signature", func() {
+		license := &lk.License{Data: content, R: sig.R, S: sig.S}
+		ok, err := license.Verify(privateKey.GetPublicKey())
+		s.Require().NoError(err)
+		s.Require().True(ok)
+	})
+
+	s.Run("should test a signature with b32", func() {
+		str, err := sig.ToB32String()
+		s.Require().NoError(err)
+		sig2, err := lk.SignatureFromB32String(str)
+		s.Require().NoError(err)
+		s.Require().Equal(sig, sig2)
+	})
+}
@@ -0,0 +1,422 @@
+// Package twoparty signs licenses with an SM2 key split between two
+// parties, so that the complete key never exists on a single machine.
+//
+// The private key d is shared multiplicatively: party 1 holds d1 and party 2
+// holds d2 with d1·d2 = (1+d)⁻¹ mod n. The shares are generated by the
+// parties, d is never computed. A signature takes one round trip in which
+// each party adds its own nonce, and the result is an ordinary SM2
+// signature verified by License.Verify with the combined public key.
+//
+// Party 1 drives the protocol and implements lk.Signer. Party 2 answers its
+// requests, typically from another service, through a Transport:
+//
+//	p2, err := twoparty.NewParty2(nil)
+//	p1, err := twoparty.GenerateKey(twoparty.MemoryTransport{Party2: p2})
+//	license, err := lk.NewClaimsLicense(p1, claims)
+//	ok, err := license.Verify(p1.GetPublicKey())
+//
+// The protocol protects the key against a party that follows it while
+// trying to learn the other share. It doesn't include the zero-knowledge
+// proofs needed against a party deviating from it, so the parties must
+// authenticate each other on the transport.
+package twoparty
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/phox/gmsm-lk"
+)
+
+var (
+	// ErrInvalidMessage is returned when a party receives a malformed
+	// message or an invalid point.
+	ErrInvalidMessage = errors.New("twoparty: invalid message")
+	// ErrNoKey is returned when party 2 is asked to sign before the key
+	// generation.
+	ErrNoKey = errors.New("twoparty: no key share")
+	// ErrKeyExists is returned when party 2 is asked to generate a key
+	// while it already holds a share.
+	ErrKeyExists = errors.New("twoparty: key share already generated")
+)
+
+// Share is the key share of a party, with the combined public key. It is
+// stored by the service of the party and must be kept secret.
+type Share struct {
+	// Party is 1 or 2.
+	Party int `json:"party"`
+	// D is the secret share.
+	D *big.Int `json:"d"`
+	// PublicKey is the combined public key, base32 encoded.
+	PublicKey string `json:"public_key"`
+}
+
+// Transport carries a request of party 1 to party 2 and returns the
+// response.
+type Transport interface {
+	RoundTrip(req []byte) ([]byte, error)
+}
+
+// MemoryTransport calls party 2 in the same process, for tests.
+type MemoryTransport struct {
+	Party2 *Party2
+}
+
+// RoundTrip implements Transport.
+func (t MemoryTransport) RoundTrip(req []byte) ([]byte, error) {
+	return t.Party2.Handle(req)
+}
+
+// request is sent by party 1.
+type request struct {
+	// Op is "keygen" or "sign".
+	Op string `json:"op"`
+	// Point is d1⁻¹·G for keygen and k1·G for sign, uncompressed.
+	Point []byte `json:"point"`
+	// Message is the message to sign.
+	Message []byte `json:"message,omitempty"`
+	// Description is what party 1 says it signs, for Party2.Approve.
+	Description string `json:"description,omitempty"`
+}
+
+// response is sent by party 2.
+type response struct {
+	// PublicKey is the combined public key for keygen.
+	PublicKey []byte `json:"public_key,omitempty"`
+	// R, S2 and S3 are the partial signature.
+	R  *big.Int `json:"r,omitempty"`
+	S2 *big.Int `json:"s2,omitempty"`
+	S3 *big.Int `json:"s3,omitempty"`
+}
+
+var curve = sm2.P256()
+
+// randScalar returns a random scalar in [1, n-1].
+func randScalar() (*big.Int, error) {
+	max := new(big.Int).Sub(curve.Params().N, big.NewInt(1))
+	k, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return nil, err
+	}
+	return k.Add(k, big.NewInt(1)), nil
+}
+
+func validScalar(k *big.Int) bool {
+	return k != nil && k.Sign() > 0 && k.Cmp(curve.Params().N) < 0
+}
+
+func marshalPoint(x, y *big.Int) []byte {
+	b := make([]byte, 65)
+	b[0] = 4
+	x.FillBytes(b[1:33])
+	y.FillBytes(b[33:])
+	return b
+}
+
+// unmarshalPoint decodes an uncompressed point, which must be on the curve
+// and not the point at infinity.
+func unmarshalPoint(b []byte) (x, y *big.Int, err error) {
+	if len(b) != 65 || b[0] != 4 {
+		return nil, nil, fmt.Errorf("%w: point", ErrInvalidMessage)
+	}
+	x, y = new(big.Int).SetBytes(b[1:33]), new(big.Int).SetBytes(b[33:])
+	if !curve.IsOnCurve(x, y) {
+		return nil, nil, fmt.Errorf("%w: point not on the curve", ErrInvalidMessage)
+	}
+	return x, y, nil
+}
+
+func scalarBytes(k *big.Int) []byte {
+	return k.FillBytes(make([]byte, 32))
+}
+
+// digest returns the SM2 digest of the message, with the default user id.
+func digest(pub *lk.PublicKey, msg []byte) (*big.Int, error) {
+	key, err := sm2.NewPublicKey(pub.ToBytes())
+	if err != nil {
+		return nil, err
+	}
+	e, err := sm2.CalculateSM2Hash(key, msg, nil)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(e), nil
+}
+
+// Party1 holds the share of party 1 and signs with party 2 through the
+// transport. It implements lk.Signer and is safe for concurrent use.
+type Party1 struct {
+	share       *Share
+	public      *lk.PublicKey
+	transport   Transport
+	description string
+}
+
+// GenerateKey generates the key shares of both parties and returns party
+// 1. Party 2 keeps its share.
+func GenerateKey(t Transport) (*Party1, error) {
+	d1, err := randScalar()
+	if err != nil {
+		return nil, err
+	}
+	x, y := curve.ScalarBaseMult(scalarBytes(new(big.Int).ModInverse(d1, curve.Params().N)))
+
+	var resp response
+	if err := call(t, &request{Op: "keygen", Point: marshalPoint(x, y)}, &resp); err != nil {
+		return nil, err
+	}
+	public, err := lk.PublicKeyFromBytes(resp.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+	return &Party1{
+		share:     &Share{Party: 1, D: d1, PublicKey: public.ToB32String()},
+		public:    public,
+		transport: t,
+	}, nil
+}
+
+// NewParty1 returns party 1 with its stored share.
+func NewParty1(share *Share, t Transport) (*Party1, error) {
+	if share == nil || share.Party != 1 || !validScalar(share.D) {
+		return nil, errors.New("twoparty: invalid share of party 1")
+	}
+	public, err := lk.PublicKeyFromB32String(share.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Party1{share: share, public: public, transport: t}, nil
+}
+
+// Share returns the share of party 1, to be stored.
+func (p *Party1) Share() *Share {
+	return p.share
+}
+
+// WithDescription returns party 1 sending the description with its
+// signature requests, given to Party2.Approve.
+func (p *Party1) WithDescription(description string) *Party1 {
+	d := *p
+	d.description = description
+	return &d
+}
+
+// GetPublicKey returns the combined public key.
+func (p *Party1) GetPublicKey() *lk.PublicKey {
+	return p.public
+}
+
+// SignMessage signs the message with party 2, with SM2 and the default
+// user id. The signature is verified before it is returned.
+func (p *Party1) SignMessage(msg []byte) (r, s *big.Int, err error) {
+	n := curve.Params().N
+	e, err := digest(p.public, msg)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := sm2.NewPublicKey(p.public.ToBytes())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for {
+		k1, err := randScalar()
+		if err != nil {
+			return nil, nil, err
+		}
+		x, y := curve.ScalarBaseMult(scalarBytes(k1))
+
+		var resp response
+		if err := call(p.transport, &request{Op: "sign", Point: marshalPoint(x, y), Message: msg, Description: p.description}, &resp); err != nil {
+			return nil, nil, err
+		}
+		if !validScalar(resp.R) || !validScalar(resp.S2) || !validScalar(resp.S3) {
+			return nil, nil, fmt.Errorf("%w: partial signature", ErrInvalidMessage)
+		}
+
+		// s = d1·k1·s2 + d1·s3 - r
+		s := new(big.Int).Mul(p.share.D, k1)
+		s.Mul(s, resp.S2)
+		s.Add(s, new(big.Int).Mul(p.share.D, resp.S3))
+		s.Sub(s, resp.R)
+		s.Mod(s, n)
+		if s.Sign() == 0 || new(big.Int).Add(s, resp.R).Cmp(n) == 0 {
+			continue
+		}
+
+		if !sm2.Verify(key, scalarBytes(e), resp.R, s) {
+			return nil, nil, fmt.Errorf("%w: the signature doesn't verify", ErrInvalidMessage)
+		}
+		return resp.R, s, nil
+	}
+}
+
+func call(t Transport, req *request, resp *response) error {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	b, err = t.RoundTrip(b)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, resp); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+	return nil
+}
+
+// Party2 holds the share of party 2 and answers the requests of party 1.
+// It is safe for concurrent use.
+type Party2 struct {
+	// Approve is called with each message before it is signed if not nil,
+	// the signature is refused if it returns an error. The message of a
+	// license is the SM3 digest of its data, so the description sent by
+	// party 1, such as the serial and subject of the license, tells what it
+	// is. It is empty if party 1 didn't set it and is not checked against
+	// the message.
+	Approve func(msg []byte, description string) error
+
+	mu     sync.Mutex
+	share  *Share
+	public *lk.PublicKey
+}
+
+// NewParty2 returns party 2 with its stored share, or nil before the key
+// generation.
+func NewParty2(share *Share) (*Party2, error) {
+	p := &Party2{}
+	if share == nil {
+		return p, nil
+	}
+	if share.Party != 2 || !validScalar(share.D) {
+		return nil, errors.New("twoparty: invalid share of party 2")
+	}
+	public, err := lk.PublicKeyFromB32String(share.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	p.share, p.public = share, public
+	return p, nil
+}
+
+// Share returns the share of party 2 to be stored, nil before the key
+// generation.
+func (p *Party2) Share() *Share {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.share
+}
+
+// Handle answers a request of party 1.
+func (p *Party2) Handle(b []byte) ([]byte, error) {
+	var req request
+	if err := json.Unmarshal(b, &req); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+	x, y, err := unmarshalPoint(req.Point)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *response
+	switch req.Op {
+	case "keygen":
+		resp, err = p.keygen(x, y)
+	case "sign":
+		resp, err = p.sign(x, y, req.Message, req.Description)
+	default:
+		err = fmt.Errorf("%w: unknown operation %q", ErrInvalidMessage, req.Op)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(resp)
+}
+
+// keygen computes the public key d2⁻¹·P1 - G from P1 = d1⁻¹·G.
+func (p *Party2) keygen(x1, y1 *big.Int) (*response, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.share != nil {
+		return nil, ErrKeyExists
+	}
+
+	params := curve.Params()
+	negGy := new(big.Int).Sub(params.P, params.Gy)
+	for {
+		d2, err := randScalar()
+		if err != nil {
+			return nil, err
+		}
+		x, y := curve.ScalarMult(x1, y1, scalarBytes(new(big.Int).ModInverse(d2, params.N)))
+		x, y = curve.Add(x, y, params.Gx, negGy)
+		// d1·d2 = 1 gives the point at infinity
+		if x.Sign() == 0 && y.Sign() == 0 {
+			continue
+		}
+
+		public, err := lk.PublicKeyFromBytes(marshalPoint(x, y))
+		if err != nil {
+			return nil, err
+		}
+		p.share = &Share{Party: 2, D: d2, PublicKey: public.ToB32String()}
+		p.public = public
+		return &response{PublicKey: public.ToBytes()}, nil
+	}
+}
+
+// sign computes the partial signature of the message from Q1 = k1·G:
+// r = e + x(k3·Q1 + k2·G), s2 = d2·k3 and s3 = d2·(r + k2).
+func (p *Party2) sign(x1, y1 *big.Int, msg []byte, description string) (*response, error) {
+	p.mu.Lock()
+	share, public := p.share, p.public
+	p.mu.Unlock()
+	if share == nil {
+		return nil, ErrNoKey
+	}
+	if p.Approve != nil {
+		if err := p.Approve(msg, description); err != nil {
+			return nil, err
+		}
+	}
+
+	n := curve.Params().N
+	e, err := digest(public, msg)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		k2, err := randScalar()
+		if err != nil {
+			return nil, err
+		}
+		k3, err := randScalar()
+		if err != nil {
+			return nil, err
+		}
+
+		x, y := curve.ScalarMult(x1, y1, scalarBytes(k3))
+		x2, y2 := curve.ScalarBaseMult(scalarBytes(k2))
+		x, _ = curve.Add(x, y, x2, y2)
+
+		r := new(big.Int).Add(e, x)
+		r.Mod(r, n)
+		if r.Sign() == 0 {
+			continue
+		}
+		s2 := new(big.Int).Mul(share.D, k3)
+		s2.Mod(s2, n)
+		s3 := new(big.Int).Add(r, k2)
+		s3.Mul(s3, share.D).Mod(s3, n)
+		if s3.Sign() == 0 {
+			continue
+		}
+		return &response{R: r, S2: s2, S3: s3}, nil
+	}
+}
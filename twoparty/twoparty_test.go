@@ -0,0 +1,87 @@
+package twoparty_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/phox/gmsm-lk"
+	"github.com/phox/gmsm-lk/twoparty"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTwoParty(t *testing.T) {
+	p2, err := twoparty.NewParty2(nil)
+	require.NoError(t, err)
+	p1, err := twoparty.GenerateKey(twoparty.MemoryTransport{Party2: p2})
+	require.NoError(t, err)
+
+	license, err := lk.NewClaimsLicense(p1, &lk.Claims{Subject: "ACME"})
+	require.NoError(t, err)
+	ok, err := license.Verify(p1.GetPublicKey())
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NoError(t, license.VerifyErr(p1.GetPublicKey()))
+
+	// the shares are stored and reloaded
+	var shares [2]*twoparty.Share
+	for i, share := range []*twoparty.Share{p1.Share(), p2.Share()} {
+		b, err := json.Marshal(share)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(b, &shares[i]))
+	}
+	p2, err = twoparty.NewParty2(shares[1])
+	require.NoError(t, err)
+	p1, err = twoparty.NewParty1(shares[0], twoparty.MemoryTransport{Party2: p2})
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		license, err = lk.NewLicense(p1, []byte{byte(i)})
+		require.NoError(t, err)
+		require.NoError(t, license.VerifyErr(p1.GetPublicKey()))
+	}
+
+	_, err = twoparty.GenerateKey(twoparty.MemoryTransport{Party2: p2})
+	require.ErrorIs(t, err, twoparty.ErrKeyExists)
+
+	// a share of another key doesn't produce valid signatures
+	other, err := twoparty.NewParty2(nil)
+	require.NoError(t, err)
+	_, err = twoparty.GenerateKey(twoparty.MemoryTransport{Party2: other})
+	require.NoError(t, err)
+	p1, err = twoparty.NewParty1(shares[0], twoparty.MemoryTransport{Party2: other})
+	require.NoError(t, err)
+	_, err = lk.NewLicense(p1, []byte("data"))
+	require.ErrorIs(t, err, twoparty.ErrInvalidMessage)
+}
+
+func TestParty2(t *testing.T) {
+	p2, err := twoparty.NewParty2(nil)
+	require.NoError(t, err)
+
+	_, err = p2.Handle([]byte(`{"op":"keygen","point":"BA=="}`))
+	require.ErrorIs(t, err, twoparty.ErrInvalidMessage)
+	_, err = p2.Handle([]byte(`{`))
+	require.ErrorIs(t, err, twoparty.ErrInvalidMessage)
+
+	p1, err := twoparty.GenerateKey(twoparty.MemoryTransport{Party2: p2})
+	require.NoError(t, err)
+
+	refused := errors.New("refused")
+	var descriptions []string
+	p2.Approve = func(msg []byte, description string) error {
+		descriptions = append(descriptions, description)
+		if string(msg) == "forbidden" {
+			return refused
+		}
+		return nil
+	}
+	_, _, err = p1.SignMessage([]byte("forbidden"))
+	require.ErrorIs(t, err, refused)
+	_, _, err = p1.WithDescription("license s1 for ACME").SignMessage([]byte("allowed"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"", "license s1 for ACME"}, descriptions)
+
+	_, err = twoparty.NewParty2(&twoparty.Share{Party: 1})
+	require.Error(t, err)
+}
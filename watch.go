@@ -0,0 +1,258 @@
+package lk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// Default settings of a Watcher.
+const (
+	DefaultWatchInterval = 30 * time.Second
+	DefaultExpiryWarning = 7 * 24 * time.Hour
+)
+
+// WatchEventType is the type of a WatchEvent.
+type WatchEventType int
+
+// The events of a Watcher.
+const (
+	// WatchInstalled is sent when a valid license is loaded and there was no
+	// valid license before.
+	WatchInstalled WatchEventType = iota
+	// WatchChanged is sent when the valid license is replaced by another
+	// valid license.
+	WatchChanged
+	// WatchInvalid is sent when the license file can't be read, decoded or
+	// verified. The previous license is dropped.
+	WatchInvalid
+	// WatchExpiring is sent once per license when its expiration date is
+	// within the ExpiryWarning of the watcher.
+	WatchExpiring
+	// WatchExpired is sent once per license when it expires.
+	WatchExpired
+)
+
+func (t WatchEventType) String() string {
+	switch t {
+	case WatchInstalled:
+		return "installed"
+	case WatchChanged:
+		return "changed"
+	case WatchInvalid:
+		return "invalid"
+	case WatchExpiring:
+		return "expiring"
+	case WatchExpired:
+		return "expired"
+	}
+	return "unknown"
+}
+
+// WatchEvent is sent to the subscribers of a Watcher. License and Claims are
+// nil for WatchInvalid, Err is set for WatchInvalid and WatchExpired.
+type WatchEvent struct {
+	Type    WatchEventType
+	License *License
+	Claims  *Claims
+	Err     error
+}
+
+// Watcher reloads a license file when it changes and tells its subscribers.
+// The file is polled, it is re-decoded and re-verified with the public key
+// each time its content changes. The current license can be read
+// concurrently with License.
+type Watcher struct {
+	// Interval is the polling interval, DefaultWatchInterval if not
+	// positive.
+	Interval time.Duration
+	// ExpiryWarning is how long before its expiration date a license is
+	// reported as expiring, DefaultExpiryWarning if not positive.
+	ExpiryWarning time.Duration
+	// Options are the checks done in addition to the signature. The
+	// validity period is checked at each poll with Clock, Options.Now is
+	// ignored.
+	Options VerifyOptions
+	// Clock returns the current time, time.Now if nil.
+	Clock func() time.Time
+
+	path string
+	key  *PublicKey
+
+	mu      sync.RWMutex
+	subs    []func(WatchEvent)
+	loaded  bool
+	data    []byte
+	license *License
+	claims  *Claims
+	err     error
+	warned  bool
+	expired bool
+}
+
+// NewWatcher returns a Watcher of the license file verified with the public
+// key. Call Run to start watching.
+func NewWatcher(path string, k *PublicKey) *Watcher {
+	return &Watcher{path: path, key: k}
+}
+
+// Subscribe adds a callback called with the events of the watcher. The
+// callbacks are called in order from the goroutine polling the file.
+func (w *Watcher) Subscribe(fn func(WatchEvent)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subs = append(w.subs, fn)
+}
+
+// License returns the current license and its claims. The error is nil if
+// the license can be used: it is set when there is no valid license, or when
+// the license is expired or not yet valid.
+func (w *Watcher) License() (*License, *Claims, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if !w.loaded {
+		return nil, nil, ErrLicenseNotFound
+	}
+	return w.license, w.claims, w.err
+}
+
+// Run reloads the license file at each interval until the context is done.
+// The file is loaded once before Run returns ctx.Err().
+func (w *Watcher) Run(ctx context.Context) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	w.Reload()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			w.Reload()
+		}
+	}
+}
+
+// Reload checks the license file and the validity period of the license now,
+// the subscribers are called with the resulting events.
+func (w *Watcher) Reload() {
+	events, subs := w.reload()
+	for _, ev := range events {
+		for _, fn := range subs {
+			fn(ev)
+		}
+	}
+}
+
+func (w *Watcher) now() time.Time {
+	if w.Clock != nil {
+		return w.Clock()
+	}
+	return time.Now()
+}
+
+func (w *Watcher) reload() ([]WatchEvent, []func(WatchEvent)) {
+	now := w.now()
+	b, readErr := os.ReadFile(w.path)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var events []WatchEvent
+	if !w.loaded || (readErr == nil) != (w.data != nil) || !bytes.Equal(b, w.data) {
+		w.loaded = true
+		w.data = nil
+
+		license, c, err := (*License)(nil), (*Claims)(nil), readErr
+		if readErr == nil {
+			// an empty file is not the same as a missing one
+			w.data = append([]byte{}, b...)
+			license, c, err = w.decode(b)
+		}
+
+		if err != nil {
+			w.license, w.claims, w.err = nil, nil, err
+			events = append(events, WatchEvent{Type: WatchInvalid, Err: err})
+		} else {
+			typ := WatchChanged
+			if w.license == nil {
+				typ = WatchInstalled
+			}
+			w.license, w.claims, w.err = license, c, nil
+			w.warned, w.expired = false, false
+			events = append(events, WatchEvent{Type: typ, License: license, Claims: c})
+		}
+	}
+
+	if w.license != nil {
+		events = append(events, w.checkValidity(now)...)
+	}
+	subs := make([]func(WatchEvent), len(w.subs))
+	copy(subs, w.subs)
+	return events, subs
+}
+
+// decode decodes and verifies the content of the license file.
+func (w *Watcher) decode(b []byte) (*License, *Claims, error) {
+	l, _, err := (&StrictDecoder{}).DecodeLicense(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := l.VerifyErr(w.key); err != nil {
+		return nil, nil, err
+	}
+	c, err := l.Claims()
+	if err != nil {
+		return nil, nil, err
+	}
+	if w.Options.Machine != "" {
+		if err := c.CheckMachine(w.Options.Machine); err != nil {
+			return nil, nil, err
+		}
+	}
+	if w.Options.Revoked != nil {
+		if err := w.Options.Revoked.Check(c); err != nil {
+			return nil, nil, err
+		}
+	}
+	if w.Options.Tracker != nil {
+		if err := w.Options.Tracker.Check(l); err != nil {
+			return nil, nil, err
+		}
+		if err := w.Options.Tracker.Observe(l); err != nil {
+			return nil, nil, err
+		}
+	}
+	return l, c, nil
+}
+
+// checkValidity updates the error of the current license with its validity
+// period and returns the expiring and expired events not sent yet.
+func (w *Watcher) checkValidity(now time.Time) []WatchEvent {
+	warning := w.ExpiryWarning
+	if warning <= 0 {
+		warning = DefaultExpiryWarning
+	}
+
+	w.err = w.claims.Valid(now)
+	switch {
+	case errors.Is(w.err, ErrExpired):
+		if !w.expired {
+			w.expired, w.warned = true, true
+			return []WatchEvent{{Type: WatchExpired, License: w.license, Claims: w.claims, Err: w.err}}
+		}
+	case w.err == nil && !w.claims.Expires.IsZero() && w.claims.Expires.Sub(now) <= warning:
+		if !w.warned {
+			w.warned = true
+			return []WatchEvent{{Type: WatchExpiring, License: w.license, Claims: w.claims}}
+		}
+	}
+	return nil
+}
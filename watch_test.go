@@ -0,0 +1,140 @@
+package lk_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	lk "github.com/phox/gmsm-lk"
+)
+
+func (s *Suite) TestWatcher() {
+	privateKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+	otherKey, err := lk.NewPrivateKey()
+	s.Require().NoError(err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	write := func(path string, k *lk.PrivateKey, c *lk.Claims) {
+		l, err := lk.NewClaimsLicense(k, c)
+		s.Require().NoError(err)
+		b, err := l.Encode(lk.EncodingHex)
+		s.Require().NoError(err)
+		// replaced atomically, the running watcher must not read a partial file
+		s.Require().NoError(os.WriteFile(path+".tmp", b, 0600))
+		s.Require().NoError(os.Rename(path+".tmp", path))
+	}
+
+	s.Run("should send the events", func() {
+		path := filepath.Join(s.T().TempDir(), "license.lic")
+		clock := now
+		w := lk.NewWatcher(path, privateKey.GetPublicKey())
+		w.ExpiryWarning = 24 * time.Hour
+		w.Clock = func() time.Time { return clock }
+
+		var events []lk.WatchEventType
+		w.Subscribe(func(ev lk.WatchEvent) { events = append(events, ev.Type) })
+		reload := func(expected ...lk.WatchEventType) {
+			events = nil
+			w.Reload()
+			s.Require().Equal(expected, events)
+		}
+
+		reload(lk.WatchInvalid)
+		_, _, err := w.License()
+		s.Require().ErrorIs(err, os.ErrNotExist)
+
+		write(path, privateKey, &lk.Claims{Serial: "1", Expires: now.Add(72 * time.Hour)})
+		reload(lk.WatchInstalled)
+		reload()
+		_, c, err := w.License()
+		s.Require().NoError(err)
+		s.Require().Equal("1", c.Serial)
+
+		write(path, privateKey, &lk.Claims{Serial: "2", Expires: now.Add(72 * time.Hour)})
+		reload(lk.WatchChanged)
+
+		clock = now.Add(50 * time.Hour)
+		reload(lk.WatchExpiring)
+		reload()
+
+		clock = now.Add(73 * time.Hour)
+		reload(lk.WatchExpired)
+		reload()
+		l, _, err := w.License()
+		s.Require().NotNil(l)
+		s.Require().ErrorIs(err, lk.ErrExpired)
+
+		write(path, otherKey, &lk.Claims{Serial: "3"})
+		reload(lk.WatchInvalid)
+		l, _, err = w.License()
+		s.Require().Nil(l)
+		s.Require().ErrorIs(err, lk.ErrUnknownKey)
+
+		write(path, privateKey, &lk.Claims{Serial: "4"})
+		reload(lk.WatchInstalled)
+	})
+
+	s.Run("should run until the context is done", func() {
+		path := filepath.Join(s.T().TempDir(), "license.lic")
+		write(path, privateKey, &lk.Claims{Serial: "1"})
+
+		w := lk.NewWatcher(path, privateKey.GetPublicKey())
+		w.Interval = 10 * time.Millisecond
+
+		var mu sync.Mutex
+		var serials []string
+		changed := make(chan struct{}, 1)
+		w.Subscribe(func(ev lk.WatchEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			serials = append(serials, ev.Claims.Serial)
+			if ev.Type == lk.WatchChanged {
+				changed <- struct{}{}
+			}
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- w.Run(ctx) }()
+
+		s.Require().Eventually(func() bool {
+			_, _, err := w.License()
+			return err == nil
+		}, time.Second, 5*time.Millisecond)
+		write(path, privateKey, &lk.Claims{Serial: "2"})
+
+		select {
+		case <-changed:
+		case <-time.After(5 * time.Second):
+			s.FailNow("no change event")
+		}
+		cancel()
+		s.Require().ErrorIs(<-done, context.Canceled)
+
+		mu.Lock()
+		defer mu.Unlock()
+		s.Require().Equal([]string{"1", "2"}, serials)
+	})
+
+	s.Run("should reject a superseded license", func() {
+		path := filepath.Join(s.T().TempDir(), "license.lic")
+		first, err := lk.NewClaimsLicense(privateKey, &lk.Claims{Serial: "1"})
+		s.Require().NoError(err)
+		second, err := lk.NewRenewal(privateKey, first, &lk.Claims{Serial: "2"})
+		s.Require().NoError(err)
+
+		w := lk.NewWatcher(path, privateKey.GetPublicKey())
+		w.Options.Tracker = lk.NewTracker()
+		for _, l := range []*lk.License{second, first} {
+			str, err := l.ToB32String()
+			s.Require().NoError(err)
+			s.Require().NoError(os.WriteFile(path, []byte(str), 0600))
+			w.Reload()
+		}
+		_, _, err = w.License()
+		s.Require().ErrorIs(err, lk.ErrSuperseded)
+	})
+}